@@ -0,0 +1,76 @@
+package cab
+
+import (
+	"io/fs"
+	"strings"
+)
+
+// FileAttributes are the attribute flags of a File.
+type FileAttributes uint16
+
+// The file attributes defined by the cabinet format.
+const (
+	AttrReadOnly  FileAttributes = 0x01
+	AttrHidden    FileAttributes = 0x02
+	AttrSystem    FileAttributes = 0x04
+	AttrArchive   FileAttributes = 0x20
+	AttrExec      FileAttributes = 0x40
+	AttrNameIsUTF FileAttributes = 0x80
+)
+
+// Attributes that the cabinet format does not define but that share their values with
+// the MS-DOS attributes of objects other than regular files. A cabinet with them set
+// is unusual and may have been crafted to create unexpected file system objects.
+const (
+	AttrVolumeLabel FileAttributes = 0x08
+	AttrDirectory   FileAttributes = 0x10
+)
+
+// regularAttrs are the attributes a regular file may have.
+const regularAttrs = AttrReadOnly | AttrHidden | AttrSystem | AttrArchive | AttrExec | AttrNameIsUTF
+
+var attrNames = []struct {
+	attr FileAttributes
+	name string
+}{
+	{AttrReadOnly, "readonly"},
+	{AttrHidden, "hidden"},
+	{AttrSystem, "system"},
+	{AttrVolumeLabel, "volume"},
+	{AttrDirectory, "directory"},
+	{AttrArchive, "archive"},
+	{AttrExec, "exec"},
+	{AttrNameIsUTF, "utf"},
+}
+
+// String returns the names of the attributes that are set, separated by "|".
+func (a FileAttributes) String() string {
+	var names []string
+	for _, n := range attrNames {
+		if a&n.attr != 0 {
+			names = append(names, n.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// IsRegular reports whether the attributes are those of a regular file: no attribute
+// is set other than the ones the cabinet format defines.
+func (a FileAttributes) IsRegular() bool {
+	return a&^regularAttrs == 0
+}
+
+// Mode returns the permission bits conventionally given to a file with these
+// attributes: 0644, without write permission if AttrReadOnly is set and with execute
+// permission if AttrExec is set. It is the mapping used unless WithAttributeMapper
+// specifies another. Hidden and system files have no equivalent and are unaffected.
+func (a FileAttributes) Mode() fs.FileMode {
+	mode := fs.FileMode(0644)
+	if a&AttrExec != 0 {
+		mode |= 0111
+	}
+	if a&AttrReadOnly != 0 {
+		mode &^= 0222
+	}
+	return mode
+}
@@ -0,0 +1,46 @@
+package cab_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestFileAttributesMode(t *testing.T) {
+	testCases := []struct {
+		attrs    cab.FileAttributes
+		expected fs.FileMode
+	}{
+		{attrs: 0, expected: 0644},
+		{attrs: cab.AttrArchive | cab.AttrHidden | cab.AttrSystem, expected: 0644},
+		{attrs: cab.AttrReadOnly, expected: 0444},
+		{attrs: cab.AttrExec, expected: 0755},
+		{attrs: cab.AttrReadOnly | cab.AttrExec, expected: 0555},
+	}
+
+	for _, tc := range testCases {
+		if actual := tc.attrs.Mode(); actual != tc.expected {
+			t.Fatalf("expected %v for %q, but got %v", tc.expected, tc.attrs, actual)
+		}
+	}
+}
+
+func TestFileAttributesIsRegular(t *testing.T) {
+	testCases := []struct {
+		attrs    cab.FileAttributes
+		expected bool
+	}{
+		{attrs: 0, expected: true},
+		{attrs: cab.AttrReadOnly | cab.AttrHidden | cab.AttrSystem | cab.AttrArchive | cab.AttrExec | cab.AttrNameIsUTF, expected: true},
+		{attrs: cab.AttrVolumeLabel, expected: false},
+		{attrs: cab.AttrDirectory | cab.AttrArchive, expected: false},
+		{attrs: 0x400, expected: false},
+	}
+
+	for _, tc := range testCases {
+		if actual := tc.attrs.IsRegular(); actual != tc.expected {
+			t.Fatalf("expected %v for %#x, but got %v", tc.expected, uint16(tc.attrs), actual)
+		}
+	}
+}
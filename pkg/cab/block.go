@@ -0,0 +1,476 @@
+package cab
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+// maxBlockSize is the largest number of uncompressed bytes a CFDATA block may hold.
+const maxBlockSize = 32768
+
+// dataHeaderSize is the size of the fixed fields that begin every CFDATA entry: the
+// 4-byte checksum followed by the 2-byte cbData and cbUncomp. They are always present,
+// whether or not checksums are verified, and are followed by the entry's reserve.
+const dataHeaderSize = 8
+
+// dataBlock is a single CFDATA entry.
+type dataBlock struct {
+	checksum         uint32
+	compressedSize   uint16
+	uncompressedSize uint16
+	data             []byte
+}
+
+// readDataBlock reads the CFDATA entry at off, reusing buf for its header and data if
+// possible, so that reading many small blocks does not allocate for each of them. It
+// returns the block along with the offset of the entry that follows it.
+func (c *Reader) readDataBlock(off int64, buf []byte) (dataBlock, int64, error) {
+	if cap(buf) < dataHeaderSize {
+		buf = make([]byte, dataHeaderSize)
+	}
+	hdr := buf[:dataHeaderSize]
+	if err := readFullAt(c.r, hdr, off); err != nil {
+		return dataBlock{}, 0, truncatedIfEOF(err)
+	}
+
+	blk := dataBlock{
+		checksum:         binary.LittleEndian.Uint32(hdr[0:4]),
+		compressedSize:   binary.LittleEndian.Uint16(hdr[4:6]),
+		uncompressedSize: binary.LittleEndian.Uint16(hdr[6:8]),
+	}
+	if blk.uncompressedSize > maxBlockSize {
+		return dataBlock{}, 0, newCorruptError("data block too large")
+	}
+
+	off += int64(len(hdr)) + int64(c.dataReserveSize)
+
+	if cap(buf) < int(blk.compressedSize) {
+		buf = make([]byte, blk.compressedSize)
+	}
+	blk.data = buf[:blk.compressedSize]
+	if err := readFullAt(c.r, blk.data, off); err != nil {
+		return dataBlock{}, 0, truncatedIfEOF(err)
+	}
+
+	return blk, off + int64(blk.compressedSize), nil
+}
+
+// blockEntry locates a CFDATA entry of a folder.
+type blockEntry struct {
+	offset             int64 // offset of the CFDATA entry in the cabinet
+	uncompressedOffset int64 // offset of the block's data in the folder stream
+	checksum           uint32
+	compressedSize     uint16
+	uncompressedSize   uint16
+}
+
+// blockIndex returns the location of each of folder's data blocks. The index is built
+// from the block headers, without decompressing, the first time it is needed.
+func (c *Reader) blockIndex(folder *Folder) ([]blockEntry, error) {
+	folder.indexOnce.Do(func() {
+		index := make([]blockEntry, 0, folder.numDataBlocks)
+		off := int64(folder.firstDataOffset)
+		var uncompressedOffset int64
+		var hdr [dataHeaderSize]byte
+		for i := 0; i < int(folder.numDataBlocks); i++ {
+			if err := readFullAt(c.r, hdr[:], off); err != nil {
+				folder.indexErr = truncatedIfEOF(err)
+				return
+			}
+
+			e := blockEntry{
+				offset:             off,
+				uncompressedOffset: uncompressedOffset,
+				checksum:           binary.LittleEndian.Uint32(hdr[0:4]),
+				compressedSize:     binary.LittleEndian.Uint16(hdr[4:6]),
+				uncompressedSize:   binary.LittleEndian.Uint16(hdr[6:8]),
+			}
+			index = append(index, e)
+			off += int64(len(hdr)) + int64(c.dataReserveSize) + int64(e.compressedSize)
+			uncompressedOffset += int64(e.uncompressedSize)
+		}
+		folder.index = index
+	})
+
+	return folder.index, folder.indexErr
+}
+
+// BlockInfo describes a CFDATA entry of a Folder.
+type BlockInfo struct {
+	// Offset is the offset of the entry in the cabinet.
+	Offset int64
+	// UncompressedOffset is the offset of the block's data in the folder's
+	// decompressed stream.
+	UncompressedOffset int64
+	// Checksum is the checksum stored in the entry, or 0 if none was stored. It is
+	// reported as stored, without being verified; see Reader.VerifyChecksums.
+	Checksum uint32
+	// CompressedSize is the number of bytes of data stored in the entry.
+	CompressedSize int
+	// UncompressedSize is the number of bytes the data decompresses to.
+	UncompressedSize int
+	// Reserve is the entry's reserved area, which some cabinets use for their own
+	// per-block data, such as signatures. It is nil if the cabinet reserves none.
+	Reserve []byte
+}
+
+// Blocks returns the folder's data blocks in order. Only the block headers and their
+// reserved areas are read; nothing is decompressed or verified.
+func (f *Folder) Blocks() ([]BlockInfo, error) {
+	index, err := f.c.blockIndex(f)
+	if err != nil {
+		return nil, err
+	}
+
+	reserveSize := int(f.c.dataReserveSize)
+	reserves := make([]byte, reserveSize*len(index))
+
+	blocks := make([]BlockInfo, len(index))
+	for i, e := range index {
+		blocks[i] = BlockInfo{
+			Offset:             e.offset,
+			UncompressedOffset: e.uncompressedOffset,
+			Checksum:           e.checksum,
+			CompressedSize:     int(e.compressedSize),
+			UncompressedSize:   int(e.uncompressedSize),
+		}
+		if reserveSize > 0 {
+			reserve := reserves[i*reserveSize : (i+1)*reserveSize : (i+1)*reserveSize]
+			if err := readFullAt(f.c.r, reserve, e.offset+dataHeaderSize); err != nil {
+				return nil, truncatedIfEOF(err)
+			}
+			blocks[i].Reserve = reserve
+		}
+	}
+	return blocks, nil
+}
+
+// BlockSize returns the number of uncompressed bytes the producer of the cabinet put in
+// each of the folder's data blocks, which is at most 32 KB. Blocks are decoded whole, so
+// it bounds the work done to read at an arbitrary offset. Every block but the last holds
+// that many bytes, and the last usually holds fewer, so the size of the largest block is
+// returned. A folder with a single block reports that block's size, which may be less
+// than the producer would have used for a larger folder; one without blocks reports 0.
+// Only the block headers are read.
+func (f *Folder) BlockSize() (int, error) {
+	index, err := f.c.blockIndex(f)
+	if err != nil {
+		return 0, err
+	}
+
+	var size int
+	for _, e := range index {
+		if int(e.uncompressedSize) > size {
+			size = int(e.uncompressedSize)
+		}
+	}
+	return size, nil
+}
+
+// folderReader reads the decompressed data of a folder as a single stream. A folder
+// continued across the cabinets of a set is read from each of its parts in turn.
+type folderReader struct {
+	part *Folder // the part of the folder whose blocks are being read
+	d    decompressor
+
+	off    int64 // offset of the next CFDATA entry in part's cabinet
+	blocks int   // number of part's blocks read so far
+	raw    []byte
+	split  []byte // the data of a block split between two parts
+	out    []byte
+	pos    int
+
+	m *metricsCollector
+}
+
+// Open returns a ReadCloser for the folder's decompressed data, the single stream of
+// which each of its files is a slice. A folder continued across the cabinets of a set
+// is read from its start, in the first cabinet, to its end, in the last. As with
+// File.Open, checksums are not verified; see Reader.VerifyChecksums.
+func (f *Folder) Open() (io.ReadCloser, error) {
+	fr, err := f.c.openFolder(f)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.NopCloser(fr), nil
+}
+
+// CopyCompressedTo writes the folder's CFDATA entries to w verbatim, in order: each
+// block's header, reserved area and data as stored, so that they can be placed in
+// another cabinet using the same compression without decompressing them. The files of a
+// folder share its compressed stream, and a block may hold parts of several of them, so
+// there is no equivalent for a single file. Only the part of a folder stored in this
+// cabinet is copied, even if the folder continues across the cabinets of a set.
+func (f *Folder) CopyCompressedTo(w io.Writer) error {
+	index, err := f.c.blockIndex(f)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range index {
+		length := dataHeaderSize + int64(f.c.dataReserveSize) + int64(e.compressedSize)
+		n, err := io.Copy(w, io.NewSectionReader(f.c.r, e.offset, length))
+		if err != nil {
+			return err
+		}
+		if n < length {
+			return truncatedIfEOF(io.ErrUnexpectedEOF)
+		}
+	}
+	return nil
+}
+
+// ReadFiles calls fn for each file in the folder, in the order the files are stored,
+// with a reader of the file's contents. The folder is decompressed once, as a single
+// stream, which is much faster than opening its files one at a time when all or most
+// of them are needed; only files that overlap one stored before them cause it to be
+// decompressed again.
+//
+// The readers share the stream, so each is only valid until fn returns. Whatever fn
+// leaves unread is skipped. If fn returns an error, ReadFiles stops and returns it.
+func (f *Folder) ReadFiles(fn func(file *File, r io.Reader) error) error {
+	return f.readFiles(f.Files, nil, fn)
+}
+
+// readFiles calls fn for each of files, which belong to f, as described by ReadFiles,
+// collecting metrics about the blocks read in m.
+func (f *Folder) readFiles(files []*File, m *metricsCollector, fn func(file *File, r io.Reader) error) error {
+	sorted := make([]*File, len(files))
+	copy(sorted, files)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].uncompressedOffset < sorted[j].uncompressedOffset
+	})
+
+	var fr *folderReader
+	var pos int64
+	for _, file := range sorted {
+		if file.uncompressedSize == 0 {
+			if err := fn(file, strings.NewReader("")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// files may overlap; start the stream over when one begins behind the current position
+		if fr == nil || int64(file.uncompressedOffset) < pos {
+			var err error
+			if fr, err = f.c.openFolder(f); err != nil {
+				return err
+			}
+			fr.m = m
+			pos = 0
+		}
+
+		skipped, err := io.CopyN(ioutil.Discard, fr, int64(file.uncompressedOffset)-pos)
+		pos += skipped
+		if err != nil {
+			return fmt.Errorf("cab: reading %q: %w", file.DecodeName(), truncatedIfEOF(err))
+		}
+
+		lr := &io.LimitedReader{R: fr, N: int64(file.uncompressedSize)}
+		err = fn(file, lr)
+		pos += int64(file.uncompressedSize) - lr.N
+		if err != nil {
+			return err
+		}
+
+		n, err := io.Copy(ioutil.Discard, lr)
+		pos += n
+		if err != nil {
+			return fmt.Errorf("cab: reading %q: %w", file.DecodeName(), err)
+		}
+		if lr.N != 0 {
+			return fmt.Errorf("cab: reading %q: %w", file.DecodeName(), truncatedIfEOF(io.ErrUnexpectedEOF))
+		}
+	}
+
+	return nil
+}
+
+// checkEmptyFolder rejects a folder without data blocks that contains non-empty files,
+// which could not be read. When opening leniently, the problem is recorded as a
+// warning instead and the files are treated as empty. A folder continued from or into
+// another cabinet may hold its data there, so it is checked when it is opened.
+func (c *Reader) checkEmptyFolder(idx int, folder *Folder) error {
+	if folder.numDataBlocks != 0 || folder.ContinuesFromPrev() || folder.ContinuesToNext() {
+		return nil
+	}
+
+	for _, file := range folder.Files {
+		if file.uncompressedSize == 0 {
+			continue
+		}
+
+		err := newCorruptError(fmt.Sprintf("folder %d has no data blocks but contains non-empty file %q", idx, file.DecodeName()))
+		if !c.opts.lenientEmpty {
+			return err
+		}
+		c.warnings = append(c.warnings, err)
+		file.uncompressedSize = 0
+	}
+
+	return nil
+}
+
+// openFolder returns a reader of the decompressed stream of folder. If the folder
+// continues one in a previous cabinet of a set, the stream starts at the beginning of
+// the first part, since that is what the offsets of its files refer to. If the
+// folder's decompressor cannot be created, the error is a *FolderError.
+func (c *Reader) openFolder(folder *Folder) (*folderReader, error) {
+	idx := folder.idx
+	folder = folder.head()
+	fr := &folderReader{
+		part: folder,
+		off:  int64(folder.firstDataOffset),
+	}
+
+	// a folder without data blocks is an empty stream, so there is nothing to decode
+	if folder.numDataBlocks == 0 && folder.next == nil {
+		for _, file := range folder.Files {
+			if file.uncompressedSize != 0 {
+				return nil, newCorruptError("folder has no data blocks but contains non-empty files")
+			}
+		}
+		return fr, nil
+	}
+
+	var err error
+	if fr.d, err = newDecompressor(folder); err != nil {
+		return nil, &FolderError{Folder: idx, Err: err}
+	}
+
+	return fr, nil
+}
+
+// readStoredAt fills p with the data at pos in the stream of an uncompressed folder.
+// The data is read directly from the blocks holding it, skipping their headers.
+func (c *Reader) readStoredAt(folder *Folder, p []byte, pos int64) error {
+	index, err := c.blockIndex(folder)
+	if err != nil {
+		return err
+	}
+
+	i := sort.Search(len(index), func(i int) bool {
+		return index[i].uncompressedOffset > pos
+	}) - 1
+	if i < 0 {
+		i = 0
+	}
+
+	for len(p) > 0 {
+		if i >= len(index) {
+			return truncatedIfEOF(io.ErrUnexpectedEOF)
+		}
+
+		e := index[i]
+		if e.compressedSize != e.uncompressedSize {
+			return newCorruptError("uncompressed block size mismatch")
+		}
+
+		within := pos - e.uncompressedOffset
+		n := int64(e.uncompressedSize) - within
+		if n > int64(len(p)) {
+			n = int64(len(p))
+		}
+
+		dataOffset := e.offset + dataHeaderSize + int64(c.dataReserveSize)
+		if err := readFullAt(c.r, p[:n], dataOffset+within); err != nil {
+			return truncatedIfEOF(err)
+		}
+
+		p = p[n:]
+		pos += n
+		i++
+	}
+
+	return nil
+}
+
+func (fr *folderReader) Read(p []byte) (int, error) {
+	for fr.pos >= len(fr.out) {
+		if err := fr.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, fr.out[fr.pos:])
+	fr.pos += n
+	return n, nil
+}
+
+// next decodes the next block, returning io.EOF after the last block of the last part.
+func (fr *folderReader) next() error {
+	for fr.blocks >= int(fr.part.numDataBlocks) {
+		if fr.part.next == nil {
+			return io.EOF
+		}
+		fr.nextPart()
+	}
+
+	blk, err := fr.readBlock()
+	if err != nil {
+		return err
+	}
+	data := blk.data
+
+	// the last block of a part may be split, with an uncompressed size of zero, and
+	// the rest of its data at the start of the next part
+	if blk.uncompressedSize == 0 && fr.blocks == int(fr.part.numDataBlocks) && fr.part.next != nil {
+		fr.split = append(fr.split[:0], blk.data...)
+		fr.nextPart()
+		if fr.part.numDataBlocks == 0 {
+			return newCorruptError("split data block is not continued")
+		}
+		if blk, err = fr.readBlock(); err != nil {
+			return err
+		}
+		fr.split = append(fr.split, blk.data...)
+		data = fr.split
+	}
+
+	start := fr.m.now()
+	fr.out, err = fr.d.decompress(fr.out, data, int(blk.uncompressedSize))
+	if err != nil {
+		return err
+	}
+	fr.m.decompressed(len(fr.out), start)
+
+	fr.pos = 0
+	return nil
+}
+
+// readBlock reads the next CFDATA entry of the current part.
+func (fr *folderReader) readBlock() (dataBlock, error) {
+	blk, next, err := fr.part.c.readDataBlock(fr.off, fr.raw)
+	if err != nil {
+		return dataBlock{}, err
+	}
+
+	fr.raw = blk.data
+	fr.off = next
+	fr.blocks++
+	fr.m.blockRead(len(blk.data))
+	return blk, nil
+}
+
+func (fr *folderReader) nextPart() {
+	fr.part = fr.part.next
+	fr.off = int64(fr.part.firstDataOffset)
+	fr.blocks = 0
+}
+
+// readFullAt reads exactly len(p) bytes from r at off.
+func readFullAt(r io.ReaderAt, p []byte, off int64) error {
+	n, err := r.ReadAt(p, off)
+	if n == len(p) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
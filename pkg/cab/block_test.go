@@ -0,0 +1,474 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestEmptyFolder(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: "empty1.txt"}, {name: "empty2.txt"}},
+		[]testFile{{name: "a.txt", data: []byte("a")}},
+	)
+
+	// the empty folder has no data blocks; give it a type that cannot be decoded to
+	// prove nothing is read from it
+	if n := binary.LittleEndian.Uint16(data[40:]); n != 0 {
+		t.Fatalf("expected 0 data blocks, but got %d", n)
+	}
+	binary.LittleEndian.PutUint16(data[42:], 0x1503)
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	for _, name := range []string{"empty1.txt", "empty2.txt"} {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if fi.Size() != 0 {
+			t.Fatalf("expected %s to be empty, but got %d byte(s)", name, fi.Size())
+		}
+	}
+}
+
+func TestEmptyFolderWithNonEmptyFile(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}},
+		[]testFile{{name: "b.txt", data: []byte("b")}},
+	)
+	// the second folder claims to have no data blocks
+	binary.LittleEndian.PutUint16(data[48:], 0)
+
+	_, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if !errors.Is(err, cab.ErrCorrupt) {
+		t.Fatalf("expected a corrupt error, but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "folder 1") || !strings.Contains(err.Error(), "b.txt") {
+		t.Fatalf("expected the error to name the folder and file, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithLenientEmptyFolders())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if warnings := r.Warnings(); len(warnings) != 1 || !errors.Is(warnings[0], cab.ErrCorrupt) {
+		t.Fatalf("expected a corrupt warning, but got %v", warnings)
+	}
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{"a.txt": "a", "b.txt": ""}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != content {
+			t.Fatalf("expected %q for %s, but got %q", content, name, b)
+		}
+	}
+}
+
+func TestFolderBlocks(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(readme), int64(len(readme)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	blocks, err := r.Folders[0].Blocks()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := []cab.BlockInfo{{
+		Offset:           0x46,
+		Checksum:         0x6b1813e5,
+		CompressedSize:   int(binary.LittleEndian.Uint16(readme[0x4a:])),
+		UncompressedSize: 38,
+	}}
+	if !reflect.DeepEqual(blocks, expected) {
+		t.Fatalf("expected %+v, but got %+v", expected, blocks)
+	}
+}
+
+func TestFolderBlockSize(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string][]byte
+		opts     []cab.WriterOption
+		expected int
+	}{
+		{
+			name:     "several blocks",
+			files:    map[string][]byte{"a.bin": make([]byte, 2500)},
+			opts:     []cab.WriterOption{cab.WithBlockSize(1000)},
+			expected: 1000,
+		},
+		{
+			name:     "default",
+			files:    map[string][]byte{"a.bin": make([]byte, 100000)},
+			expected: 32768,
+		},
+		{
+			name:     "single block",
+			files:    map[string][]byte{"a.bin": make([]byte, 38)},
+			expected: 38,
+		},
+		{
+			name:  "no blocks",
+			files: map[string][]byte{"a.bin": nil},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := cab.WriteFiles(&buf, tc.files, tc.opts...); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			size, err := r.Folders[0].BlockSize()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if size != tc.expected {
+				t.Fatalf("expected %d, but got %d", tc.expected, size)
+			}
+		})
+	}
+}
+
+func TestFolderBlocksWithReserve(t *testing.T) {
+	const reserveSize = 5
+	content := bytes.Repeat([]byte("0123456789"), 4000)
+
+	data := withDataReserve(buildCab([]testFile{{name: "a.bin", data: content}}), reserveSize)
+	// give the blocks checksums, which must not be mistaken for part of the reserve,
+	// and reserves of their own
+	var reserves [][]byte
+	for off := int(binary.LittleEndian.Uint32(data[40:])); off < len(data); {
+		cbData := int(binary.LittleEndian.Uint16(data[off+4:]))
+		binary.LittleEndian.PutUint32(data[off:], 0xdeadbeef)
+		reserve := bytes.Repeat([]byte{byte('A' + len(reserves))}, reserveSize)
+		copy(data[off+8:], reserve)
+		reserves = append(reserves, reserve)
+		off += 8 + reserveSize + cbData
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	blocks, err := r.Folders[0].Blocks()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if len(blocks) != 2 {
+		t.Fatalf("expected 2 blocks, but got %d", len(blocks))
+	}
+	var uncompressedOffset int64
+	for i, blk := range blocks {
+		if blk.Checksum != 0xdeadbeef {
+			t.Fatalf("expected block %d to have checksum 0xdeadbeef, but got 0x%08x", i, blk.Checksum)
+		}
+		if !bytes.Equal(blk.Reserve, reserves[i]) {
+			t.Fatalf("expected block %d to have reserve %q, but got %q", i, reserves[i], blk.Reserve)
+		}
+		if blk.UncompressedOffset != uncompressedOffset {
+			t.Fatalf("expected block %d at %d, but got %d", i, uncompressedOffset, blk.UncompressedOffset)
+		}
+		uncompressedOffset += int64(blk.UncompressedSize)
+	}
+	if uncompressedOffset != int64(len(content)) {
+		t.Fatalf("expected %d bytes, but got %d", len(content), uncompressedOffset)
+	}
+
+	if b := readFile(t, r.Folders[0].Files[0]); !bytes.Equal(b, content) {
+		t.Fatalf("unexpected content")
+	}
+}
+
+func TestFolderCopyCompressedTo(t *testing.T) {
+	const reserveSize = 3
+	data := withDataReserve(buildMSZIPCab(
+		[]testFile{{name: "a.bin", data: bytes.Repeat([]byte("0123456789"), 5000)}},
+		[]testFile{{name: "b.txt", data: []byte("hello")}, {name: "c.txt", data: []byte("world")}},
+	), reserveSize)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	for i, folder := range r.Folders {
+		blocks, err := folder.Blocks()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		var expected []byte
+		for _, blk := range blocks {
+			end := blk.Offset + 8 + reserveSize + int64(blk.CompressedSize)
+			expected = append(expected, data[blk.Offset:end]...)
+		}
+
+		var buf bytes.Buffer
+		if err := folder.CopyCompressedTo(&buf); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), expected) {
+			t.Fatalf("expected folder %d to copy %d bytes of blocks, but got %d bytes", i, len(expected), buf.Len())
+		}
+	}
+
+	t.Run("truncated", func(t *testing.T) {
+		// the data ends early, although the size given does not
+		truncated := data[:len(data)-1]
+		r, err := cab.NewReader(bytes.NewReader(truncated), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		last := r.Folders[len(r.Folders)-1]
+		if err := last.CopyCompressedTo(ioutil.Discard); !errors.Is(err, cab.ErrTruncated) {
+			t.Fatalf("expected ErrTruncated, but got %v", err)
+		}
+	})
+}
+
+// withDataReserve rewrites a cabinet built by buildCab so that every CFDATA entry has
+// a reserve of n bytes.
+func withDataReserve(data []byte, n uint8) []byte {
+	le := binary.LittleEndian
+	numFolders := int(le.Uint16(data[26:]))
+	coffFiles := le.Uint32(data[16:])
+	const shift = 4 // cbCFHeader, cbCFFolder and cbCFData
+
+	out := append([]byte(nil), data[:36]...)
+	le.PutUint16(out[30:], le.Uint16(out[30:])|0x4)
+	le.PutUint32(out[16:], coffFiles+shift)
+	out = append(out, 0, 0, 0, n)
+
+	folders := len(out)
+	out = append(out, data[36:coffFiles]...)
+	out = append(out, data[coffFiles:le.Uint32(data[36:])]...)
+
+	for i := 0; i < numFolders; i++ {
+		entry := 36 + 8*i
+		off := le.Uint32(data[entry:])
+		le.PutUint32(out[folders+8*i:], uint32(len(out)))
+		for j := 0; j < int(le.Uint16(data[entry+4:])); j++ {
+			cbData := uint32(le.Uint16(data[off+4:]))
+			out = append(out, data[off:off+8]...)
+			out = append(out, bytes.Repeat([]byte{0xaa}, int(n))...)
+			out = append(out, data[off+8:off+8+cbData]...)
+			off += 8 + cbData
+		}
+	}
+
+	le.PutUint32(out[8:], uint32(len(out)))
+	return out
+}
+
+func TestFolderReadFiles(t *testing.T) {
+	var files []testFile
+	for i := 0; i < 20; i++ {
+		files = append(files, testFile{
+			name: fmt.Sprintf("%02d.txt", i),
+			data: bytes.Repeat([]byte{byte('a' + i)}, 5000+i),
+		})
+	}
+	files = append(files, testFile{name: "empty.txt"})
+	data := buildMSZIPCab(files)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var names []string
+	err = r.Folders[0].ReadFiles(func(file *cab.File, rd io.Reader) error {
+		names = append(names, file.Name)
+
+		// leave every other file partly unread
+		if len(names)%2 == 0 {
+			var b [1]byte
+			_, err := io.ReadFull(rd, b[:])
+			return err
+		}
+
+		b, err := ioutil.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(b, readFile(t, file)) {
+			t.Fatalf("unexpected content for %s", file.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(names) != len(files) {
+		t.Fatalf("expected %d files, but got %d", len(files), len(names))
+	}
+
+	stop := errors.New("stop")
+	var calls int
+	err = r.Folders[0].ReadFiles(func(*cab.File, io.Reader) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Fatalf("expected to stop after the first file, but got %v after %d call(s)", err, calls)
+	}
+}
+
+func BenchmarkFolderReadFiles(b *testing.B) {
+	var files []testFile
+	for i := 0; i < 100; i++ {
+		content := make([]byte, 10000)
+		rand.New(rand.NewSource(int64(i))).Read(content)
+		files = append(files, testFile{name: fmt.Sprintf("%03d.bin", i), data: content})
+	}
+	data := buildMSZIPCab(files)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		b.Fatalf("expected no error, but got %v", err)
+	}
+	folder := r.Folders[0]
+
+	b.Run("ReadFiles", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := folder.ReadFiles(func(_ *cab.File, rd io.Reader) error {
+				_, err := io.Copy(ioutil.Discard, rd)
+				return err
+			})
+			if err != nil {
+				b.Fatalf("expected no error, but got %v", err)
+			}
+		}
+	})
+
+	b.Run("Open", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, file := range folder.Files {
+				rc, err := file.Open()
+				if err != nil {
+					b.Fatalf("expected no error, but got %v", err)
+				}
+				if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+					b.Fatalf("expected no error, but got %v", err)
+				}
+				rc.Close()
+			}
+		}
+	})
+}
+
+func BenchmarkFolderReadBlockSize(b *testing.B) {
+	// a folder holds at most 65535 blocks, which bounds the content at the smallest size
+	content := make([]byte, 32*1024)
+	rand.New(rand.NewSource(1)).Read(content)
+
+	for _, blockSize := range []int{1, 512, 32768} {
+		b.Run(fmt.Sprint(blockSize), func(b *testing.B) {
+			var buf bytes.Buffer
+			files := map[string][]byte{"a.bin": content}
+			if err := cab.WriteFiles(&buf, files, cab.WithBlockSize(blockSize)); err != nil {
+				b.Fatalf("expected no error, but got %v", err)
+			}
+			r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				b.Fatalf("expected no error, but got %v", err)
+			}
+			folder := r.Folders[0]
+
+			b.SetBytes(int64(len(content)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				rc, err := folder.Open()
+				if err != nil {
+					b.Fatalf("expected no error, but got %v", err)
+				}
+				if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+					b.Fatalf("expected no error, but got %v", err)
+				}
+				rc.Close()
+			}
+		})
+	}
+}
+
+func TestFolderOpen(t *testing.T) {
+	files := []testFile{
+		{name: "a.txt", data: bytes.Repeat([]byte("a"), 40000)},
+		{name: "b.txt", data: []byte("b")},
+	}
+
+	for name, data := range map[string][]byte{"stored": buildCab(files), "MSZIP": buildMSZIPCab(files)} {
+		t.Run(name, func(t *testing.T) {
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			rc, err := r.Folders[0].Open()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer rc.Close()
+
+			b, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if expected := append(append([]byte(nil), files[0].data...), files[1].data...); !bytes.Equal(b, expected) {
+				t.Fatalf("expected the concatenated contents of the files")
+			}
+		})
+	}
+}
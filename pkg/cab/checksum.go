@@ -0,0 +1,227 @@
+package cab
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// ErrChecksum is matched by errors reporting a data block whose stored checksum does
+// not match its contents.
+var ErrChecksum = errors.New("cab: checksum mismatch")
+
+// ChecksumError reports a data block whose stored checksum does not match its contents.
+type ChecksumError struct {
+	Folder   int
+	Block    int
+	Stored   uint32
+	Computed uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("cab: checksum mismatch in folder %d, block %d: stored 0x%08x, computed 0x%08x", e.Folder, e.Block, e.Stored, e.Computed)
+}
+
+// Is reports whether target is ErrChecksum or ErrCorrupt.
+func (e *ChecksumError) Is(target error) bool {
+	return target == ErrChecksum || target == ErrCorrupt
+}
+
+// Checksum folds p into seed using the cabinet checksum algorithm: p is XORed into
+// the seed as little-endian 32-bit words, with any trailing 1-3 bytes combined
+// big-endian into a final word. The checksum stored in a CFDATA entry is
+//
+//	Checksum(cbDataAndCbUncomp, Checksum(data, 0))
+//
+// where cbDataAndCbUncomp holds the entry's 2-byte cbData and cbUncomp fields as they
+// appear in the cabinet.
+func Checksum(p []byte, seed uint32) uint32 {
+	csum := seed
+	for len(p) >= 4 {
+		csum ^= binary.LittleEndian.Uint32(p)
+		p = p[4:]
+	}
+
+	var ul uint32
+	for _, b := range p {
+		ul = ul<<8 | uint32(b)
+	}
+
+	return csum ^ ul
+}
+
+// NewChecksum returns a hash.Hash32 computing Checksum incrementally, starting from
+// seed. The data may be written in pieces of any size; the result is the same as
+// Checksum over their concatenation. Sum appends the checksum in big-endian order.
+func NewChecksum(seed uint32) hash.Hash32 {
+	return &checksumDigest{seed: seed, csum: seed}
+}
+
+type checksumDigest struct {
+	seed    uint32
+	csum    uint32
+	pending [4]byte // bytes of an incomplete word
+	n       int
+}
+
+func (d *checksumDigest) Write(p []byte) (int, error) {
+	written := len(p)
+	if d.n > 0 {
+		m := copy(d.pending[d.n:], p)
+		d.n += m
+		p = p[m:]
+		if d.n < len(d.pending) {
+			return written, nil
+		}
+		d.csum ^= binary.LittleEndian.Uint32(d.pending[:])
+		d.n = 0
+	}
+
+	for len(p) >= 4 {
+		d.csum ^= binary.LittleEndian.Uint32(p)
+		p = p[4:]
+	}
+	d.n = copy(d.pending[:], p)
+
+	return written, nil
+}
+
+func (d *checksumDigest) Sum32() uint32 {
+	return Checksum(d.pending[:d.n], d.csum)
+}
+
+func (d *checksumDigest) Sum(b []byte) []byte {
+	s := d.Sum32()
+	return append(b, byte(s>>24), byte(s>>16), byte(s>>8), byte(s))
+}
+
+func (d *checksumDigest) Reset() {
+	d.csum = d.seed
+	d.n = 0
+}
+
+func (d *checksumDigest) Size() int      { return 4 }
+func (d *checksumDigest) BlockSize() int { return 4 }
+
+// blockChecksum computes the checksum of a CFDATA entry.
+func blockChecksum(compressedSize, uncompressedSize uint16, data []byte) uint32 {
+	return sizesChecksum(compressedSize, uncompressedSize, Checksum(data, 0))
+}
+
+// sizesChecksum folds a CFDATA entry's cbData and cbUncomp into the checksum of its
+// data, completing the entry's checksum.
+func sizesChecksum(compressedSize, uncompressedSize uint16, dataChecksum uint32) uint32 {
+	var sizes [4]byte
+	binary.LittleEndian.PutUint16(sizes[0:2], compressedSize)
+	binary.LittleEndian.PutUint16(sizes[2:4], uncompressedSize)
+	return Checksum(sizes[:], dataChecksum)
+}
+
+// VerifyChecksums reads every data block in the cabinet and compares its stored
+// checksum against its contents, without decompressing anything. Blocks with a zero
+// checksum have none stored and are skipped. It returns nil when every stored
+// checksum matches, or a *ChecksumError for the first block that does not.
+//
+// Each block's data is checksummed as it is read, through a small buffer, so blocks
+// are never held in memory whole.
+func (c *Reader) VerifyChecksums() error {
+	if err := c.checkFiles(); err != nil {
+		return err
+	}
+
+	m := c.newMetricsCollector()
+	defer c.reportMetrics(m)
+
+	buf := make([]byte, 4096)
+	h := NewChecksum(0)
+	for i, folder := range c.Folders {
+		index, err := c.blockIndex(folder)
+		if err != nil {
+			return err
+		}
+
+		for j, e := range index {
+			if e.uncompressedSize > maxBlockSize {
+				return newCorruptError("data block too large")
+			}
+			if e.checksum == 0 {
+				continue
+			}
+
+			start := m.now()
+			computed, err := c.computeChecksum(e, h, buf)
+			if err != nil {
+				return err
+			}
+			m.checksummed(start)
+			m.blockRead(int(e.compressedSize))
+			if computed != e.checksum {
+				return &ChecksumError{Folder: i, Block: j, Stored: e.checksum, Computed: computed}
+			}
+		}
+	}
+
+	return nil
+}
+
+// RepairChecksums recomputes the checksum of every data block in the cabinet held by
+// rw, whose size is size, and writes those that differ from the stored checksum back
+// in place, including where none was stored. Nothing but the checksum fields is
+// written. It returns the number of checksums written, which is useful after editing a
+// cabinet's bytes directly.
+func RepairChecksums(rw interface {
+	io.ReaderAt
+	io.WriterAt
+}, size int64) (int, error) {
+	c, err := NewReader(rw, size)
+	if err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 4096)
+	h := NewChecksum(0)
+	var fixed int
+	for _, folder := range c.Folders {
+		index, err := c.blockIndex(folder)
+		if err != nil {
+			return fixed, err
+		}
+
+		for _, e := range index {
+			computed, err := c.computeChecksum(e, h, buf)
+			if err != nil {
+				return fixed, err
+			}
+			if computed == e.checksum {
+				continue
+			}
+
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], computed)
+			if _, err := rw.WriteAt(b[:], e.offset); err != nil {
+				return fixed, err
+			}
+			fixed++
+		}
+	}
+
+	return fixed, nil
+}
+
+// computeChecksum computes the checksum of the data block e by streaming its data
+// through h, using buf to read it.
+func (c *Reader) computeChecksum(e blockEntry, h hash.Hash32, buf []byte) (uint32, error) {
+	h.Reset()
+	off := e.offset + dataHeaderSize + int64(c.dataReserveSize)
+	n, err := io.CopyBuffer(h, io.NewSectionReader(c.r, off, int64(e.compressedSize)), buf)
+	if err != nil {
+		return 0, err
+	}
+	if n < int64(e.compressedSize) {
+		return 0, truncatedIfEOF(io.ErrUnexpectedEOF)
+	}
+
+	return sizesChecksum(e.compressedSize, e.uncompressedSize, h.Sum32()), nil
+}
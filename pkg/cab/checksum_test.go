@@ -0,0 +1,215 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestChecksum(t *testing.T) {
+	testCases := []struct {
+		p        []byte
+		seed     uint32
+		expected uint32
+	}{
+		{p: nil, seed: 0, expected: 0},
+		{p: []byte{1, 2, 3, 4}, seed: 0, expected: 0x04030201},
+		{p: []byte{1, 2, 3, 4, 5}, seed: 0, expected: 0x04030201 ^ 0x05},
+		{p: []byte{1, 2, 3, 4, 5, 6}, seed: 0, expected: 0x04030201 ^ 0x0506},
+		{p: []byte{1, 2, 3, 4, 5, 6, 7}, seed: 0, expected: 0x04030201 ^ 0x050607},
+		{p: []byte{1, 2, 3, 4}, seed: 0xffffffff, expected: 0xfbfcfdfe},
+	}
+
+	for _, tc := range testCases {
+		if actual := cab.Checksum(tc.p, tc.seed); actual != tc.expected {
+			t.Fatalf("expected 0x%08x for % x, but got 0x%08x", tc.expected, tc.p, actual)
+		}
+	}
+}
+
+func TestReaderVerifyChecksums(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(readme), int64(len(readme)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := r.VerifyChecksums(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// flip a bit in the compressed data
+	corrupted := append([]byte(nil), readme...)
+	corrupted[0x60] ^= 0x01
+
+	r, err = cab.NewReader(bytes.NewReader(corrupted), int64(len(corrupted)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	err = r.VerifyChecksums()
+	if !errors.Is(err, cab.ErrChecksum) {
+		t.Fatalf("expected a checksum error, but got %v", err)
+	}
+	var ce *cab.ChecksumError
+	if !errors.As(err, &ce) {
+		t.Fatalf("expected a ChecksumError, but got %T", err)
+	}
+	if ce.Folder != 0 || ce.Block != 0 || ce.Stored != 0x6b1813e5 {
+		t.Fatalf("unexpected checksum error %+v", ce)
+	}
+
+	// blocks without a stored checksum are not verified
+	data := buildCab([]testFile{{name: "a.txt", data: []byte("a")}}, []testFile{{name: "b.txt", data: []byte("b")}})
+	r, err = cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := r.VerifyChecksums(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestNewChecksum(t *testing.T) {
+	p := make([]byte, 103)
+	rand.New(rand.NewSource(1)).Read(p)
+	expected := cab.Checksum(p, 0x12345678)
+
+	for _, size := range []int{1, 2, 3, 4, 5, 7, 64, len(p)} {
+		h := cab.NewChecksum(0x12345678)
+		for i := 0; i < len(p); i += size {
+			end := i + size
+			if end > len(p) {
+				end = len(p)
+			}
+			h.Write(p[i:end])
+		}
+
+		if actual := h.Sum32(); actual != expected {
+			t.Fatalf("expected 0x%08x writing %d bytes at a time, but got 0x%08x", expected, size, actual)
+		}
+
+		h.Reset()
+		h.Write(p)
+		if actual := h.Sum32(); actual != expected {
+			t.Fatalf("expected 0x%08x after reset, but got 0x%08x", expected, actual)
+		}
+	}
+}
+
+// setChecksums stores the checksum of every data block in the cabinet data.
+func setChecksums(tb testing.TB, data []byte) {
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		tb.Fatalf("expected no error, but got %v", err)
+	}
+
+	for _, folder := range r.Folders {
+		blocks, err := folder.Blocks()
+		if err != nil {
+			tb.Fatalf("expected no error, but got %v", err)
+		}
+		for _, blk := range blocks {
+			off := int(blk.Offset)
+			csum := cab.Checksum(data[off+8:off+8+blk.CompressedSize], 0)
+			binary.LittleEndian.PutUint32(data[off:], cab.Checksum(data[off+4:off+8], csum))
+		}
+	}
+}
+
+func BenchmarkReaderVerifyChecksums(b *testing.B) {
+	content := make([]byte, 64*32768)
+	rand.New(rand.NewSource(1)).Read(content)
+	data := buildCab([]testFile{{name: "large.bin", data: content}})
+	setChecksums(b, data)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		b.Fatalf("expected no error, but got %v", err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(content)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := r.VerifyChecksums(); err != nil {
+			b.Fatalf("expected no error, but got %v", err)
+		}
+	}
+}
+
+func TestRepairChecksums(t *testing.T) {
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf, cab.WithBlockSize(1000))
+	writeFile(t, w, "a.txt", bytes.Repeat([]byte("a"), 3000))
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// edit the file's content in the second block
+	data := buf.Bytes()
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	blocks, err := r.Folders[0].Blocks()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	edited := blocks[1].Offset
+	data[edited+8] = 'b'
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "edited.cab")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer f.Close()
+
+	for _, expected := range []int{1, 0} {
+		n, err := cab.RepairChecksums(f, int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if n != expected {
+			t.Fatalf("expected %d checksum(s) to be repaired, but got %d", expected, n)
+		}
+	}
+
+	repaired, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	for i := range data {
+		if repaired[i] != data[i] && (int64(i) < edited || int64(i) >= edited+4) {
+			t.Fatalf("expected only the checksum to change, but byte %d did", i)
+		}
+	}
+
+	r, err = cab.NewReader(bytes.NewReader(repaired), int64(len(repaired)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := r.VerifyChecksums(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package cab
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// CompressionType is the method used to compress the data of a Folder.
+type CompressionType uint8
+
+// The compression types defined by the cabinet format.
+const (
+	CompressionNone    CompressionType = 0
+	CompressionMSZIP   CompressionType = 1
+	CompressionQuantum CompressionType = 2
+	CompressionLZX     CompressionType = 3
+)
+
+// String returns the name of the compression type.
+func (t CompressionType) String() string {
+	switch t {
+	case CompressionNone:
+		return "none"
+	case CompressionMSZIP:
+		return "MSZIP"
+	case CompressionQuantum:
+		return "Quantum"
+	case CompressionLZX:
+		return "LZX"
+	default:
+		return fmt.Sprintf("CompressionType(%d)", uint8(t))
+	}
+}
+
+// CompressionTypesUsed returns the distinct compression types used by the cabinet's
+// folders, in ascending order. It only inspects folder metadata, so it is a cheap way
+// to check whether the cabinet can be extracted before starting.
+func (c *Reader) CompressionTypesUsed() []CompressionType {
+	var used [16]bool
+	for _, folder := range c.Folders {
+		used[folder.compressionType] = true
+	}
+
+	var types []CompressionType
+	for t, ok := range used {
+		if ok {
+			types = append(types, CompressionType(t))
+		}
+	}
+	return types
+}
+
+// ErrUnsupportedCompression is matched by errors returned when a folder uses a
+// compression type that cannot be decoded.
+var ErrUnsupportedCompression = errors.New("cab: unsupported compression type")
+
+// UnsupportedCompressionError is returned when a folder uses a compression type that
+// cannot be decoded. Callers may use it to skip or report such folders.
+type UnsupportedCompressionError struct {
+	CompressionType CompressionType
+	WindowBits      int
+}
+
+func (e *UnsupportedCompressionError) Error() string {
+	return fmt.Sprintf("cab: unsupported compression type %v (window bits %d)", e.CompressionType, e.WindowBits)
+}
+
+// Is reports whether target is ErrUnsupportedCompression.
+func (e *UnsupportedCompressionError) Is(target error) bool {
+	return target == ErrUnsupportedCompression
+}
+
+// decompressor decompresses the CFDATA blocks of a single folder, in order.
+type decompressor interface {
+	// decompress decodes src, which holds the data of one CFDATA block, into dst
+	// and returns the resulting slice. The decoded block must be exactly size bytes.
+	decompress(dst, src []byte, size int) ([]byte, error)
+}
+
+func newDecompressor(folder *Folder) (decompressor, error) {
+	switch folder.compressionType {
+	case CompressionNone:
+		return storeDecompressor{}, nil
+	case CompressionMSZIP:
+		if folder.numDataBlocks == 1 && folder.next == nil {
+			return mszipBlockDecompressor{}, nil
+		}
+		return &mszipDecompressor{}, nil
+	}
+
+	t, bits := folder.compressionType, int(folder.compressionBits)
+
+	decompressorsMu.RLock()
+	f, fallback := decompressors[t], fallbackDecompressor
+	decompressorsMu.RUnlock()
+
+	var (
+		d   Decompressor
+		err error
+	)
+	switch {
+	case f != nil:
+		d, err = f(bits)
+	case fallback != nil:
+		d, err = fallback(t, bits)
+	default:
+		err = &UnsupportedCompressionError{CompressionType: t, WindowBits: bits}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, &UnsupportedCompressionError{CompressionType: t, WindowBits: bits}
+	}
+	return registeredDecompressor{d}, nil
+}
+
+// Decompressor decodes the data blocks of a folder compressed with a type this
+// package does not implement. A new Decompressor is created for each pass over a
+// folder and given its blocks in order, so it may carry state, such as a history
+// window, from one block to the next.
+type Decompressor interface {
+	// Decompress decodes src, which holds the data of one block, and returns the
+	// result appended to dst[:0]. The decoded block must be exactly size bytes.
+	Decompress(dst, src []byte, size int) ([]byte, error)
+}
+
+var (
+	decompressorsMu      sync.RWMutex
+	decompressors        = map[CompressionType]func(windowBits int) (Decompressor, error){}
+	fallbackDecompressor func(t CompressionType, windowBits int) (Decompressor, error)
+)
+
+// RegisterDecompressor registers a function creating Decompressors for the given
+// compression type, such as Quantum or LZX. It panics if the type is already
+// registered or is decoded by this package.
+func RegisterDecompressor(t CompressionType, f func(windowBits int) (Decompressor, error)) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	if _, ok := decompressors[t]; ok || t == CompressionNone || t == CompressionMSZIP {
+		panic(fmt.Sprintf("cab: decompressor already registered for %v", t))
+	}
+	decompressors[t] = f
+}
+
+// RegisterFallbackDecompressor registers a function creating Decompressors for any
+// compression type that is neither decoded by this package nor registered with
+// RegisterDecompressor, which take precedence. The function may return an error
+// matching ErrUnsupportedCompression for types it does not handle. Registering a
+// fallback replaces the previous one, and registering nil removes it.
+func RegisterFallbackDecompressor(f func(t CompressionType, windowBits int) (Decompressor, error)) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	fallbackDecompressor = f
+}
+
+// Compressor compresses the data blocks of a folder written by a Writer, for a
+// compression type registered with RegisterCompressor. A new Compressor is created for
+// each Writer, and its blocks are passed to it in order, so it may keep history from one
+// block to the next.
+type Compressor interface {
+	// Compress encodes src, the uncompressed data of one block, appending it to dst,
+	// and returns the resulting slice. The encoded block must be no larger than 65535
+	// bytes.
+	Compress(dst, src []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[CompressionType]func(windowBits int) (Compressor, error){}
+)
+
+// RegisterCompressor registers a function creating Compressors for the given
+// compression type, so that a Writer can be created with WithCompression(t), as
+// archive/zip's RegisterCompressor does for its methods. The function is called with
+// the window size given by WithWindowBits and may return an error if it does not
+// support it. RegisterCompressor panics if the type is already registered or is
+// encoded by this package.
+func RegisterCompressor(t CompressionType, f func(windowBits int) (Compressor, error)) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+
+	if _, ok := compressors[t]; ok || t == CompressionNone || t == CompressionMSZIP {
+		panic(fmt.Sprintf("cab: compressor already registered for %v", t))
+	}
+	compressors[t] = f
+}
+
+// newCompressor returns a Compressor for t, a type registered with RegisterCompressor.
+func newCompressor(t CompressionType, windowBits int) (Compressor, error) {
+	compressorsMu.RLock()
+	f := compressors[t]
+	compressorsMu.RUnlock()
+
+	if f == nil {
+		return nil, &UnsupportedCompressionError{CompressionType: t, WindowBits: windowBits}
+	}
+	return f(windowBits)
+}
+
+// registeredDecompressor adapts a registered Decompressor, checking the size of
+// each block it decodes.
+type registeredDecompressor struct {
+	d Decompressor
+}
+
+func (r registeredDecompressor) decompress(dst, src []byte, size int) ([]byte, error) {
+	out, err := r.d.Decompress(dst, src, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(out) != size {
+		return nil, newCorruptError("decompressed block size mismatch")
+	}
+	return out, nil
+}
+
+type storeDecompressor struct{}
+
+func (storeDecompressor) decompress(dst, src []byte, size int) ([]byte, error) {
+	if len(src) != size {
+		return nil, newCorruptError("uncompressed block size mismatch")
+	}
+
+	return append(dst[:0], src...), nil
+}
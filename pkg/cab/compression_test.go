@@ -0,0 +1,335 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestUnsupportedCompression(t *testing.T) {
+	data := buildCab([]testFile{{name: "a.txt", data: []byte("a")}})
+
+	// typeCompress of the first folder: LZX with a 21 bit window
+	binary.LittleEndian.PutUint16(data[42:], 0x1503)
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	err = r.ExtractTo(dir)
+	if !errors.Is(err, cab.ErrUnsupportedCompression) {
+		t.Fatalf("expected ErrUnsupportedCompression, but got %v", err)
+	}
+
+	var uce *cab.UnsupportedCompressionError
+	if !errors.As(err, &uce) {
+		t.Fatalf("expected an UnsupportedCompressionError, but got %T", err)
+	}
+	if uce.CompressionType != cab.CompressionLZX {
+		t.Fatalf("expected %v, but got %v", cab.CompressionLZX, uce.CompressionType)
+	}
+	if uce.WindowBits != 21 {
+		t.Fatalf("expected 21 window bits, but got %d", uce.WindowBits)
+	}
+}
+
+// upperDecompressor "decodes" stored data by upper casing it.
+type upperDecompressor struct{}
+
+func (upperDecompressor) Decompress(dst, src []byte, size int) ([]byte, error) {
+	return append(dst[:0], strings.ToUpper(string(src))...), nil
+}
+
+// copyDecompressor "decodes" stored data by copying it.
+type copyDecompressor struct{}
+
+func (copyDecompressor) Decompress(dst, src []byte, size int) ([]byte, error) {
+	return append(dst[:0], src...), nil
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	const specific, fallback, declined = cab.CompressionType(13), cab.CompressionType(14), cab.CompressionType(12)
+
+	cab.RegisterDecompressor(specific, func(windowBits int) (cab.Decompressor, error) {
+		return upperDecompressor{}, nil
+	})
+	defer cab.UnregisterDecompressor(specific)
+
+	var fallbackCalls []cab.CompressionType
+	cab.RegisterFallbackDecompressor(func(t cab.CompressionType, windowBits int) (cab.Decompressor, error) {
+		fallbackCalls = append(fallbackCalls, t)
+		if t != fallback || windowBits != 15 {
+			return nil, &cab.UnsupportedCompressionError{CompressionType: t, WindowBits: windowBits}
+		}
+		return copyDecompressor{}, nil
+	})
+	defer cab.RegisterFallbackDecompressor(nil)
+
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("specific")}},
+		[]testFile{{name: "b.txt", data: []byte("fallback")}},
+		[]testFile{{name: "c.txt", data: []byte("declined")}},
+	)
+
+	// typeCompress of each folder, with a 15 bit window
+	for i, typ := range []cab.CompressionType{specific, fallback, declined} {
+		binary.LittleEndian.PutUint16(data[36+8*i+6:], 0x0f00|uint16(typ))
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	for name, expected := range map[string]string{"a.txt": "SPECIFIC", "b.txt": "fallback"} {
+		file, _ := r.FileByName(name)
+		if b := readFile(t, file); string(b) != expected {
+			t.Fatalf("expected %q, but got %q", expected, b)
+		}
+	}
+
+	file, _ := r.FileByName("c.txt")
+	if _, err := file.Open(); !errors.Is(err, cab.ErrUnsupportedCompression) {
+		t.Fatalf("expected ErrUnsupportedCompression, but got %v", err)
+	}
+
+	// the specific registration wins, so the fallback only sees the other types
+	if expected := []cab.CompressionType{fallback, declined}; !reflect.DeepEqual(fallbackCalls, expected) {
+		t.Fatalf("expected the fallback to be called for %v, but got %v", expected, fallbackCalls)
+	}
+
+	cab.RegisterFallbackDecompressor(nil)
+	file, _ = r.FileByName("b.txt")
+	if _, err := file.Open(); !errors.Is(err, cab.ErrUnsupportedCompression) {
+		t.Fatalf("expected ErrUnsupportedCompression without a fallback, but got %v", err)
+	}
+}
+
+func TestExtractToSkipUndecodableFolders(t *testing.T) {
+	const failing = cab.CompressionType(11)
+
+	errInit := errors.New("unsupported window")
+	cab.RegisterDecompressor(failing, func(windowBits int) (cab.Decompressor, error) {
+		return nil, errInit
+	})
+	defer cab.UnregisterDecompressor(failing)
+
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}},
+		[]testFile{{name: "b.txt", data: []byte("b")}},
+		[]testFile{{name: "c.txt", data: []byte("c")}},
+	)
+
+	// typeCompress of the second folder
+	binary.LittleEndian.PutUint16(data[36+8+6:], 0x0f00|uint16(failing))
+
+	testCases := []struct {
+		name string
+		opts []cab.ReaderOption
+	}{
+		{name: "strict"},
+		{name: "skip", opts: []cab.ReaderOption{cab.WithSkipUndecodableFolders()}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cab")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), tc.opts...)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			err = r.ExtractTo(dir)
+			if tc.opts == nil {
+				var fe *cab.FolderError
+				if !errors.As(err, &fe) || fe.Folder != 1 || !errors.Is(err, errInit) {
+					t.Fatalf("expected a FolderError for folder 1, but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			for name, expected := range map[string]string{"a.txt": "a", "c.txt": "c"} {
+				b, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if string(b) != expected {
+					t.Fatalf("expected %q, but got %q", expected, b)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(dir, "b.txt")); !os.IsNotExist(err) {
+				t.Fatalf("expected b.txt to not be extracted, but got %v", err)
+			}
+
+			// extracting again does not record the folder again
+			if err := r.ExtractTo(dir); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			warnings := r.Warnings()
+			if len(warnings) != 1 {
+				t.Fatalf("expected 1 warning, but got %v", warnings)
+			}
+			var fe *cab.FolderError
+			if !errors.As(warnings[0], &fe) || fe.Folder != 1 || !errors.Is(fe, errInit) {
+				t.Fatalf("expected a FolderError for folder 1, but got %v", warnings[0])
+			}
+		})
+	}
+}
+
+// xorCompressor "compresses" data by flipping bits, which xorDecompressor undoes.
+type xorCompressor struct{}
+
+func (xorCompressor) Compress(dst, src []byte) ([]byte, error) {
+	for _, c := range src {
+		dst = append(dst, c^0x55)
+	}
+	return dst, nil
+}
+
+type xorDecompressor struct{}
+
+func (xorDecompressor) Decompress(dst, src []byte, size int) ([]byte, error) {
+	return xorCompressor{}.Compress(dst[:0], src)
+}
+
+func TestRegisterCompressor(t *testing.T) {
+	const custom, unregistered = cab.CompressionType(13), cab.CompressionType(14)
+
+	var windowBits []int
+	cab.RegisterCompressor(custom, func(bits int) (cab.Compressor, error) {
+		windowBits = append(windowBits, bits)
+		return xorCompressor{}, nil
+	})
+	defer cab.UnregisterCompressor(custom)
+	cab.RegisterDecompressor(custom, func(bits int) (cab.Decompressor, error) {
+		return xorDecompressor{}, nil
+	})
+	defer cab.UnregisterDecompressor(custom)
+
+	content := bytes.Repeat([]byte("0123456789"), 10000)
+	var buf bytes.Buffer
+	opts := []cab.WriterOption{cab.WithCompression(custom), cab.WithWindowBits(15)}
+	if err := cab.WriteFiles(&buf, map[string][]byte{"a.txt": content}, opts...); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	data := buf.Bytes()
+
+	if typeCompress := binary.LittleEndian.Uint16(data[42:]); typeCompress != 0x0f00|uint16(custom) {
+		t.Fatalf("expected typeCompress 0x%04x, but got 0x%04x", 0x0f00|uint16(custom), typeCompress)
+	}
+	if bytes.Contains(data, content[:100]) {
+		t.Fatalf("expected the data to be compressed")
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if b := readFile(t, r.File[0]); !bytes.Equal(b, content) {
+		t.Fatalf("unexpected content")
+	}
+
+	// a folder that already uses a registered type is compressed again, not copied
+	buf.Reset()
+	if err := cab.Repack(&buf, r, opts...); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	repacked, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if b := readFile(t, repacked.File[0]); !bytes.Equal(b, content) {
+		t.Fatalf("unexpected repacked content")
+	}
+
+	if expected := []int{15, 15}; !reflect.DeepEqual(windowBits, expected) {
+		t.Fatalf("expected the compressor to be created with %v, but got %v", expected, windowBits)
+	}
+
+	errorCases := []struct {
+		name string
+		opts []cab.WriterOption
+	}{
+		{name: "unregistered", opts: []cab.WriterOption{cab.WithCompression(unregistered)}},
+		{name: "window bits for MSZIP", opts: []cab.WriterOption{cab.WithCompression(cab.CompressionMSZIP), cab.WithWindowBits(15)}},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := cab.NewWriter(ioutil.Discard, tc.opts...)
+			if _, err := w.Create("a.txt"); !errors.Is(err, cab.ErrUnsupportedCompression) {
+				t.Fatalf("expected ErrUnsupportedCompression, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestRegisterCompressorBuiltIn(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic registering a built in type")
+		}
+	}()
+	cab.RegisterCompressor(cab.CompressionNone, func(int) (cab.Compressor, error) {
+		return xorCompressor{}, nil
+	})
+}
+
+func TestRegisterDecompressorBuiltIn(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected a panic registering a built in type")
+		}
+	}()
+	cab.RegisterDecompressor(cab.CompressionMSZIP, func(int) (cab.Decompressor, error) {
+		return copyDecompressor{}, nil
+	})
+}
+
+func TestReaderCompressionTypesUsed(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}},
+		[]testFile{{name: "b.txt", data: []byte("b")}},
+		[]testFile{{name: "c.txt", data: []byte("c")}},
+		[]testFile{{name: "d.txt", data: []byte("d")}},
+	)
+
+	// typeCompress of each folder
+	for i, typeCompress := range []uint16{0x1503, 0x0000, 0x0001, 0x1003} {
+		binary.LittleEndian.PutUint16(data[36+8*i+6:], typeCompress)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := []cab.CompressionType{cab.CompressionNone, cab.CompressionMSZIP, cab.CompressionLZX}
+	if actual := r.CompressionTypesUsed(); !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %v, but got %v", expected, actual)
+	}
+}
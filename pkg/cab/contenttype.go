@@ -0,0 +1,40 @@
+package cab
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// ContentType returns the MIME type of the file. It is detected from the first 512
+// bytes of the file's contents using http.DetectContentType, which only decodes the
+// blocks holding those bytes. When detection is inconclusive, either generic binary or
+// plain text, the type associated with the file's extension is used instead, if there
+// is one. The result is cached.
+func (f *File) ContentType() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.contentType != "" {
+		return f.contentType, nil
+	}
+
+	b, err := f.Peek(sniffLen)
+	if err != nil {
+		return "", err
+	}
+
+	ct := http.DetectContentType(b)
+	if ct == "application/octet-stream" || strings.HasPrefix(ct, "text/plain") {
+		if byExt := mime.TypeByExtension(path.Ext(normalizeName(f.DecodeName()))); byExt != "" {
+			ct = byExt
+		}
+	}
+
+	f.contentType = ct
+	return ct, nil
+}
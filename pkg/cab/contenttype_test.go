@@ -0,0 +1,48 @@
+package cab_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestFileContentType(t *testing.T) {
+	png := append([]byte("\x89PNG\x0d\x0a\x1a\x0a"), bytes.Repeat([]byte{0}, 1000)...)
+
+	testCases := []struct {
+		file     testFile
+		expected string
+	}{
+		{file: testFile{name: "image", data: png}, expected: "image/png"},
+		{file: testFile{name: `dir\image.txt`, data: png}, expected: "image/png"},
+		{file: testFile{name: "data.json", data: []byte(`{"a": 1}`)}, expected: "application/json"},
+		{file: testFile{name: "notes", data: []byte("hello")}, expected: "text/plain; charset=utf-8"},
+		{file: testFile{name: "empty.json"}, expected: "application/json"},
+		{file: testFile{name: "unknown", data: []byte{0, 1, 2, 3}}, expected: "application/octet-stream"},
+	}
+
+	var files []testFile
+	for _, tc := range testCases {
+		files = append(files, tc.file)
+	}
+	data := buildCab(files)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	for i, tc := range testCases {
+		file := r.Folders[0].Files[i]
+		for j := 0; j < 2; j++ {
+			ct, err := file.ContentType()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if ct != tc.expected {
+				t.Fatalf("expected %q for %s, but got %q", tc.expected, file.Name, ct)
+			}
+		}
+	}
+}
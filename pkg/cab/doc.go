@@ -0,0 +1,16 @@
+// Package cab reads and writes Microsoft cabinet (.cab) archives.
+//
+// The Reader's File list, Open method and File.FileInfo mirror archive/zip, so code
+// written against a zip.Reader needs few changes to read cabinets. Zip archives have
+// no folders; in a cabinet, folders only group files that are compressed together, so
+// the flat File list is all most callers need. Use Folders when that grouping matters,
+// such as to read many files with Folder.ReadFiles.
+//
+// Likewise, FileHeader, FileInfoHeader, NewWriter, Writer.Create, Writer.CreateHeader
+// and Writer.Close, and RegisterCompressor and RegisterDecompressor, have the shapes of
+// their archive/zip namesakes. The differences follow from the format: names use
+// backslashes, times have no zone and a two second resolution, attributes are DOS
+// attributes rather than a Unix mode, a compression type applies to a whole folder
+// rather than to each file, so a Writer has one, and a cabinet may be one volume of a
+// set, read with SetReader, rather than a whole archive.
+package cab
@@ -0,0 +1,311 @@
+package cab
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// maxDumpStringLen bounds how far Dump looks for the terminator of a string.
+const maxDumpStringLen = 256
+
+// Dump writes a best-effort, offset-annotated report of the structure of the cabinet
+// in r to w: the header fields, each folder entry, each file entry (including the raw
+// bytes of its name) and each folder's data blocks. Unlike NewReader, Dump does not
+// stop at the first problem. Anything that looks corrupt is annotated with a line
+// starting with "!!" and the walk continues as far as the data allows. The returned
+// error only reports failures writing to w.
+func Dump(r io.ReaderAt, size int64, w io.Writer) error {
+	d := dumper{r: r, size: size, w: w}
+	d.dump()
+	return d.err
+}
+
+type dumper struct {
+	r    io.ReaderAt
+	size int64
+	w    io.Writer
+	off  int64
+	err  error
+}
+
+type dumpFolder struct {
+	firstDataOffset uint32
+	numDataBlocks   uint16
+}
+
+func (d *dumper) dump() {
+	d.printf("CFHEADER @ 0x%08x\n", 0)
+	sig, ok := d.bytes("signature", 4)
+	if !ok {
+		return
+	}
+	if !HasMagic(sig) {
+		d.corrupt("invalid signature %q", sig)
+	}
+
+	if _, ok = d.uint32("reserved1"); !ok {
+		return
+	}
+	cbCabinet, ok := d.uint32("cbCabinet")
+	if !ok {
+		return
+	}
+	if int64(cbCabinet) > d.size {
+		d.corrupt("cbCabinet %d exceeds the available %d bytes", cbCabinet, d.size)
+	}
+	if _, ok = d.uint32("reserved2"); !ok {
+		return
+	}
+	coffFiles, ok := d.uint32("coffFiles")
+	if !ok {
+		return
+	}
+	if int64(coffFiles) >= d.size {
+		d.corrupt("coffFiles 0x%08x is beyond the end of the data", coffFiles)
+	}
+	if _, ok = d.uint32("reserved3"); !ok {
+		return
+	}
+	if _, ok = d.uint8("versionMinor"); !ok {
+		return
+	}
+	if _, ok = d.uint8("versionMajor"); !ok {
+		return
+	}
+	numFolders, ok := d.uint16("cFolders")
+	if !ok {
+		return
+	}
+	numFiles, ok := d.uint16("cFiles")
+	if !ok {
+		return
+	}
+	flags, ok := d.uint16("flags")
+	if !ok {
+		return
+	}
+	if _, ok = d.uint16("setID"); !ok {
+		return
+	}
+	if _, ok = d.uint16("iCabinet"); !ok {
+		return
+	}
+
+	var cabinetReserveSize uint16
+	var folderReserveSize, dataReserveSize uint8
+	if flags&flagReservePresent != 0 {
+		if cabinetReserveSize, ok = d.uint16("cbCFHeader"); !ok {
+			return
+		}
+		if folderReserveSize, ok = d.uint8("cbCFFolder"); !ok {
+			return
+		}
+		if dataReserveSize, ok = d.uint8("cbCFData"); !ok {
+			return
+		}
+		if _, ok = d.bytes("abReserve", int(cabinetReserveSize)); !ok {
+			return
+		}
+	}
+
+	if flags&flagPrevCabinet != 0 {
+		if _, ok = d.string("szCabinetPrev"); !ok {
+			return
+		}
+		if _, ok = d.string("szDiskPrev"); !ok {
+			return
+		}
+	}
+	if flags&flagNextCabinet != 0 {
+		if _, ok = d.string("szCabinetNext"); !ok {
+			return
+		}
+		if _, ok = d.string("szDiskNext"); !ok {
+			return
+		}
+	}
+
+	folders := make([]dumpFolder, 0, numFolders)
+	for i := 0; i < int(numFolders); i++ {
+		d.printf("CFFOLDER[%d] @ 0x%08x\n", i, d.off)
+		var folder dumpFolder
+		if folder.firstDataOffset, ok = d.uint32("coffCabStart"); !ok {
+			break
+		}
+		if folder.numDataBlocks, ok = d.uint16("cCFData"); !ok {
+			break
+		}
+		typeCompress, ok := d.peekUint16("typeCompress")
+		if !ok {
+			break
+		}
+		d.field("typeCompress", 2, fmt.Sprintf("0x%04x (%v, bits %d)", typeCompress, CompressionType(typeCompress&0x000f), (typeCompress>>8)&0x001f))
+		if folderReserveSize > 0 {
+			if _, ok = d.bytes("abReserve", int(folderReserveSize)); !ok {
+				break
+			}
+		}
+		folders = append(folders, folder)
+	}
+
+	d.off = int64(coffFiles)
+	for i := 0; i < int(numFiles); i++ {
+		d.printf("CFFILE[%d] @ 0x%08x\n", i, d.off)
+		if _, ok = d.uint32("cbFile"); !ok {
+			break
+		}
+		if _, ok = d.uint32("uoffFolderStart"); !ok {
+			break
+		}
+		folderIdx, ok := d.uint16("iFolder")
+		if !ok {
+			break
+		}
+		if !isContinuation(folderIdx, flags) && int(folderIdx) >= int(numFolders) {
+			d.corrupt("iFolder %d is out of range for %d folder(s)", folderIdx, numFolders)
+		}
+		if _, ok = d.uint16("date"); !ok {
+			break
+		}
+		if _, ok = d.uint16("time"); !ok {
+			break
+		}
+		if _, ok = d.uint16("attribs"); !ok {
+			break
+		}
+		if _, ok = d.string("szName"); !ok {
+			break
+		}
+	}
+
+	for i, folder := range folders {
+		d.off = int64(folder.firstDataOffset)
+		for j := 0; j < int(folder.numDataBlocks); j++ {
+			d.printf("CFDATA[%d][%d] @ 0x%08x\n", i, j, d.off)
+			if _, ok = d.uint32("csum"); !ok {
+				break
+			}
+			cbData, ok := d.uint16("cbData")
+			if !ok {
+				break
+			}
+			cbUncomp, ok := d.uint16("cbUncomp")
+			if !ok {
+				break
+			}
+			if cbUncomp > maxBlockSize {
+				d.corrupt("cbUncomp %d exceeds the maximum of %d", cbUncomp, maxBlockSize)
+			}
+			if dataReserveSize > 0 {
+				if _, ok = d.bytes("abReserve", int(dataReserveSize)); !ok {
+					break
+				}
+			}
+			if d.off+int64(cbData) > d.size {
+				d.corrupt("block data extends beyond the end of the data")
+				break
+			}
+			d.printf("  0x%08x  %-16s %d byte(s)\n", d.off, "ab", cbData)
+			d.off += int64(cbData)
+		}
+	}
+}
+
+func (d *dumper) printf(format string, args ...interface{}) {
+	if d.err != nil {
+		return
+	}
+	_, d.err = fmt.Fprintf(d.w, format, args...)
+}
+
+func (d *dumper) field(name string, n int, value string) {
+	d.printf("  0x%08x  %-16s %s\n", d.off, name, value)
+	d.off += int64(n)
+}
+
+func (d *dumper) corrupt(format string, args ...interface{}) {
+	d.printf("  !! "+format+"\n", args...)
+}
+
+// read reads n bytes at the current offset, annotating the report if they are not
+// all available.
+func (d *dumper) read(name string, n int) ([]byte, bool) {
+	p := make([]byte, n)
+	if err := readFullAt(d.r, p, d.off); err != nil {
+		d.corrupt("reading %s at 0x%08x: %v", name, d.off, err)
+		return nil, false
+	}
+	return p, true
+}
+
+func (d *dumper) bytes(name string, n int) ([]byte, bool) {
+	p, ok := d.read(name, n)
+	if ok {
+		d.field(name, n, fmt.Sprintf("% x", p))
+	}
+	return p, ok
+}
+
+func (d *dumper) uint8(name string) (uint8, bool) {
+	p, ok := d.read(name, 1)
+	if !ok {
+		return 0, false
+	}
+	d.field(name, 1, fmt.Sprint(p[0]))
+	return p[0], true
+}
+
+func (d *dumper) uint16(name string) (uint16, bool) {
+	v, ok := d.peekUint16(name)
+	if ok {
+		d.field(name, 2, fmt.Sprint(v))
+	}
+	return v, ok
+}
+
+func (d *dumper) peekUint16(name string) (uint16, bool) {
+	p, ok := d.read(name, 2)
+	if !ok {
+		return 0, false
+	}
+	return binary.LittleEndian.Uint16(p), true
+}
+
+func (d *dumper) uint32(name string) (uint32, bool) {
+	p, ok := d.read(name, 4)
+	if !ok {
+		return 0, false
+	}
+	v := binary.LittleEndian.Uint32(p)
+	d.field(name, 4, fmt.Sprint(v))
+	return v, true
+}
+
+// string reads a NUL-terminated string and reports both its value and raw bytes.
+func (d *dumper) string(name string) (string, bool) {
+	n := int64(maxDumpStringLen)
+	if remaining := d.size - d.off; remaining < n {
+		n = remaining
+	}
+	if n <= 0 {
+		d.corrupt("reading %s at 0x%08x: %v", name, d.off, io.ErrUnexpectedEOF)
+		return "", false
+	}
+
+	p, ok := d.read(name, int(n))
+	if !ok {
+		return "", false
+	}
+
+	end := bytes.IndexByte(p, 0)
+	if end < 0 {
+		d.corrupt("%s at 0x%08x has no terminator within %d byte(s)", name, d.off, n)
+		return "", false
+	}
+
+	s := string(p[:end])
+	d.field(name, end+1, fmt.Sprintf("%q [% x]", s, p[:end]))
+	return s, true
+}
@@ -0,0 +1,68 @@
+package cab_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestDump(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		contains []string
+	}{
+		{
+			name: "valid",
+			data: data,
+			contains: []string{
+				"CFHEADER @ 0x00000000",
+				"CFFOLDER[0] @ 0x00000024",
+				"MSZIP",
+				`"README.md" [52 45 41 44 4d 45 2e 6d 64]`,
+				"CFDATA[0][0] @ 0x00000046",
+			},
+		},
+		{
+			name: "truncated file table",
+			data: data[:0x40],
+			contains: []string{
+				"CFFOLDER[0] @ 0x00000024",
+				"CFFILE[0] @ 0x0000002c",
+				"!! szName at 0x0000003c has no terminator",
+				"!! reading csum at 0x00000046",
+			},
+		},
+		{
+			name: "bad signature",
+			data: append([]byte("XSCF"), data[4:]...),
+			contains: []string{
+				`!! invalid signature "XSCF"`,
+				`"README.md"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := cab.Dump(bytes.NewReader(tc.data), int64(len(tc.data)), &buf); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			for _, s := range tc.contains {
+				if !strings.Contains(buf.String(), s) {
+					t.Fatalf("expected output to contain %q, but got:\n%s", s, buf.String())
+				}
+			}
+		})
+	}
+}
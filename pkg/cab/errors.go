@@ -0,0 +1,106 @@
+package cab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// ErrNotCabinet is returned when the data does not begin with the cabinet
+	// signature. Callers probing the type of a file may safely ignore it.
+	ErrNotCabinet = errors.New("cab: not a cabinet file")
+
+	// ErrCorrupt is matched by errors returned when the data has the cabinet signature
+	// but its structure is invalid. Such errors may also match a more specific
+	// reason, such as ErrTruncated.
+	ErrCorrupt = errors.New("cab: corrupt cabinet")
+
+	// ErrTruncated is matched by errors returned when the data ends before the
+	// structures it describes.
+	ErrTruncated = errors.New("cab: truncated cabinet")
+
+	// ErrSetIDMismatch is matched by errors returned when cabinets given as a set have
+	// different set IDs, and so belong to different sets.
+	ErrSetIDMismatch = errors.New("cab: cabinets belong to different sets")
+
+	// ErrDuplicateSetIndex is matched by errors returned when cabinets given as a set
+	// include two with the same index in it.
+	ErrDuplicateSetIndex = errors.New("cab: cabinets have the same index in their set")
+
+	// ErrHeaderOnly is returned by methods that need a cabinet's files when it was
+	// opened with WithHeaderOnly, which leaves them unread.
+	ErrHeaderOnly = errors.New("cab: only the header was read")
+)
+
+// FolderError reports that a folder's data could not be decoded at all, such as because
+// the decompressor registered for its compression type failed to initialize. Other
+// folders of the cabinet may still be readable; see WithSkipUndecodableFolders.
+type FolderError struct {
+	// Folder is the index of the folder in Reader.Folders.
+	Folder int
+	// Err is the reason the folder could not be decoded.
+	Err error
+}
+
+func (e *FolderError) Error() string {
+	return fmt.Sprintf("cab: folder %d: %v", e.Folder, e.Err)
+}
+
+func (e *FolderError) Unwrap() error {
+	return e.Err
+}
+
+// VolumeError reports a cabinet given to NewSetReader that does not fit the set, as
+// identified by its position among the volumes.
+type VolumeError struct {
+	// Volume is the position of the cabinet among the volumes given.
+	Volume int
+	// Err is the reason the cabinet does not fit, which matches ErrSetIDMismatch or
+	// ErrDuplicateSetIndex.
+	Err error
+}
+
+func (e *VolumeError) Error() string {
+	return fmt.Sprintf("cab: volume %d: %v", e.Volume, e.Err)
+}
+
+func (e *VolumeError) Unwrap() error {
+	return e.Err
+}
+
+// corruptError reports a structural problem in a cabinet. It matches ErrCorrupt and
+// wraps the specific reason.
+type corruptError struct {
+	err error
+}
+
+func newCorruptError(reason string) error {
+	return &corruptError{err: errors.New(reason)}
+}
+
+func (e *corruptError) Error() string {
+	if e.err == ErrTruncated {
+		return ErrTruncated.Error()
+	}
+	return ErrCorrupt.Error() + ": " + e.err.Error()
+}
+
+func (e *corruptError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is ErrCorrupt.
+func (e *corruptError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+// truncatedIfEOF converts an unexpected end of data into an error matching both
+// ErrCorrupt and ErrTruncated. Other errors, such as those from the underlying
+// source, are returned unchanged.
+func truncatedIfEOF(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &corruptError{err: ErrTruncated}
+	}
+	return err
+}
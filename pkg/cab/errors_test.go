@@ -0,0 +1,153 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io/ioutil"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderErrors(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	badFolder := buildCab([]testFile{{name: "a.txt", data: []byte("a")}})
+	binary.LittleEndian.PutUint16(badFolder[52:], 5)
+
+	// the cabinet ends at the start or in the middle of the last file's name
+	truncatedName := func(n int) []byte {
+		data := append([]byte(nil), badFolder[:n]...)
+		binary.LittleEndian.PutUint16(data[52:], 0)
+		binary.LittleEndian.PutUint32(data[8:], uint32(n))
+		return data
+	}
+
+	// the cabinet is linked to another whose name is empty, though its disk is named
+	emptyRef := func(prev bool) []byte {
+		data := linkVolume(buildCab([]testFile{{name: "a.txt", data: []byte("a")}}), prev, !prev)
+		for _, ref := range []string{"prev.cab\x00\x00", "next.cab\x00\x00"} {
+			data = bytes.Replace(data, []byte(ref), []byte("\x00disk two\x00"), 1)
+		}
+		return data
+	}
+
+	testCases := []struct {
+		name       string
+		data       []byte
+		expected   []error
+		unexpected []error
+	}{
+		{
+			name:       "empty",
+			data:       nil,
+			expected:   []error{cab.ErrNotCabinet},
+			unexpected: []error{cab.ErrCorrupt},
+		},
+		{
+			name:       "zip",
+			data:       []byte("PK\x03\x04\x14\x00\x00\x00\x08\x00"),
+			expected:   []error{cab.ErrNotCabinet},
+			unexpected: []error{cab.ErrCorrupt},
+		},
+		{
+			name:       "truncated header",
+			data:       readme[:20],
+			expected:   []error{cab.ErrCorrupt, cab.ErrTruncated},
+			unexpected: []error{cab.ErrNotCabinet},
+		},
+		{
+			name:       "truncated folder table",
+			data:       readme[:40],
+			expected:   []error{cab.ErrCorrupt, cab.ErrTruncated},
+			unexpected: []error{cab.ErrNotCabinet},
+		},
+		{
+			name:       "declared size exceeds data",
+			data:       readme[:100],
+			expected:   []error{cab.ErrCorrupt, cab.ErrTruncated},
+			unexpected: []error{cab.ErrNotCabinet},
+		},
+		{
+			name:       "truncated before name",
+			data:       truncatedName(60),
+			expected:   []error{cab.ErrCorrupt, cab.ErrTruncated},
+			unexpected: []error{cab.ErrNotCabinet},
+		},
+		{
+			name:       "truncated name",
+			data:       truncatedName(62),
+			expected:   []error{cab.ErrCorrupt, cab.ErrTruncated},
+			unexpected: []error{cab.ErrNotCabinet},
+		},
+		{
+			name:       "previous cabinet without a name",
+			data:       emptyRef(true),
+			expected:   []error{cab.ErrCorrupt},
+			unexpected: []error{cab.ErrNotCabinet, cab.ErrTruncated},
+		},
+		{
+			name:       "next cabinet without a name",
+			data:       emptyRef(false),
+			expected:   []error{cab.ErrCorrupt},
+			unexpected: []error{cab.ErrNotCabinet, cab.ErrTruncated},
+		},
+		{
+			name:       "folder index out of range",
+			data:       badFolder,
+			expected:   []error{cab.ErrCorrupt},
+			unexpected: []error{cab.ErrNotCabinet, cab.ErrTruncated},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := cab.NewReader(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err == nil {
+				t.Fatalf("expected an error, but got none")
+			}
+
+			for _, target := range tc.expected {
+				if !errors.Is(err, target) {
+					t.Fatalf("expected %v to match %v", err, target)
+				}
+			}
+			for _, target := range tc.unexpected {
+				if errors.Is(err, target) {
+					t.Fatalf("expected %v to not match %v", err, target)
+				}
+			}
+		})
+	}
+}
+
+func TestFileOpenTruncated(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// the data block ends before the declared size of the cabinet
+	data := append([]byte(nil), readme...)
+	binary.LittleEndian.PutUint32(data[8:], 100)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	rc, err := r.Folders[0].Files[0].Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer rc.Close()
+
+	_, err = ioutil.ReadAll(rc)
+	if !errors.Is(err, cab.ErrTruncated) || !errors.Is(err, cab.ErrCorrupt) {
+		t.Fatalf("expected a truncated error, but got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package cab
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileExpectation describes a file that ExtractVerified expects a cabinet to hold.
+type FileExpectation struct {
+	// Size is the expected size of the file, or -1 if it is not checked.
+	Size int64
+	// SHA256 is the expected SHA-256 digest of the file's contents, or nil if it is
+	// not checked.
+	SHA256 []byte
+}
+
+// Discrepancy is a way in which a cabinet differs from the files expected of it.
+type Discrepancy struct {
+	Path    string
+	Problem string
+}
+
+// ExpectationError is returned by ExtractVerified when a cabinet does not hold exactly
+// the expected files. It lists every discrepancy, ordered by path.
+type ExpectationError struct {
+	Discrepancies []Discrepancy
+}
+
+func (e *ExpectationError) Error() string {
+	problems := make([]string, len(e.Discrepancies))
+	for i, d := range e.Discrepancies {
+		problems[i] = d.Path + ": " + d.Problem
+	}
+	return fmt.Sprintf("cab: %d discrepancies with the expected files: %s", len(problems), strings.Join(problems, "; "))
+}
+
+// ExtractVerified extracts the cabinet into dir, as ExtractTo does, provided that it
+// holds exactly the expected files. Expected files are keyed by path, using forward
+// slashes as in Manifest. The names and sizes of every file are checked before anything
+// is written, so a cabinet missing an expected file, holding one that is not expected or
+// holding one of a different size leaves dir untouched. Hashes are checked as the files
+// are written, so that what is verified is what was written without decompressing the
+// cabinet twice. Each such file is written to a temporary file beside its path and only
+// moved into place once its hash matches, so a file whose hash differs is never found
+// in dir and never replaces one already there; the other files are extracted. In either
+// case the returned error is an *ExpectationError listing every discrepancy found.
+func (c *Reader) ExtractVerified(dir string, expected map[string]FileExpectation) error {
+	if err := c.checkFiles(); err != nil {
+		return err
+	}
+
+	var discrepancies []Discrepancy
+	seen := make(map[string]bool, len(expected))
+	hc := &hashCheck{expected: make(map[*File][]byte)}
+
+	for _, folder := range c.Folders {
+		for _, file := range folder.Files {
+			path := normalizeName(file.DecodeName())
+			e, ok := expected[path]
+			switch {
+			case !ok:
+				discrepancies = append(discrepancies, Discrepancy{Path: path, Problem: "not expected"})
+				continue
+			case seen[path]:
+				discrepancies = append(discrepancies, Discrepancy{Path: path, Problem: "duplicated"})
+				continue
+			}
+			seen[path] = true
+
+			if e.Size >= 0 && file.Size() != e.Size {
+				discrepancies = append(discrepancies, Discrepancy{Path: path, Problem: fmt.Sprintf("size is %d, expected %d", file.Size(), e.Size)})
+			}
+			if e.SHA256 != nil {
+				hc.expected[file] = e.SHA256
+			}
+		}
+	}
+
+	for path := range expected {
+		if !seen[path] {
+			discrepancies = append(discrepancies, Discrepancy{Path: path, Problem: "missing"})
+		}
+	}
+
+	if len(discrepancies) == 0 {
+		if err := c.extractToFunc(dir, nil, hc); err != nil {
+			return err
+		}
+		discrepancies = hc.mismatched
+	}
+
+	if len(discrepancies) > 0 {
+		sort.SliceStable(discrepancies, func(i, j int) bool {
+			return discrepancies[i].Path < discrepancies[j].Path
+		})
+		return &ExpectationError{Discrepancies: discrepancies}
+	}
+	return nil
+}
+
+// hashCheck holds the expected SHA-256 digests of files extracted by ExtractVerified
+// and collects the files whose contents differ. Folders are extracted concurrently, so
+// mismatches are recorded under mu.
+type hashCheck struct {
+	expected map[*File][]byte
+
+	mu         sync.Mutex
+	mismatched []Discrepancy
+}
+
+// hasher returns the hash to which the contents of file are written while extracting
+// it, or nil if its digest is not checked.
+func (hc *hashCheck) hasher(file *File) hash.Hash {
+	if hc == nil {
+		return nil
+	}
+	if _, ok := hc.expected[file]; !ok {
+		return nil
+	}
+	return sha256.New()
+}
+
+// check compares the digest in h with the one expected of file, recording the
+// discrepancy if they differ. It reports whether they match.
+func (hc *hashCheck) check(file *File, h hash.Hash) bool {
+	sum, want := h.Sum(nil), hc.expected[file]
+	if bytes.Equal(sum, want) {
+		return true
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.mismatched = append(hc.mismatched, Discrepancy{
+		Path:    normalizeName(file.DecodeName()),
+		Problem: fmt.Sprintf("SHA-256 is %x, expected %x", sum, want),
+	})
+	return false
+}
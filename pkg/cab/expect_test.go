@@ -0,0 +1,179 @@
+package cab_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func sha256Sum(p []byte) []byte {
+	sum := sha256.Sum256(p)
+	return sum[:]
+}
+
+func TestExtractVerified(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	data := buildMSZIPCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}, {name: `dir\large.bin`, data: large}},
+		[]testFile{{name: "b.txt", data: []byte("b")}},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	t.Run("match", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cab")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		expected := map[string]cab.FileExpectation{
+			"a.txt":         {Size: 1, SHA256: sha256Sum([]byte("a"))},
+			"dir/large.bin": {Size: -1, SHA256: sha256Sum(large)},
+			"b.txt":         {Size: 1},
+		}
+		if err := r.ExtractVerified(dir, expected); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(dir, "dir", "large.bin"))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(b, large) {
+			t.Fatalf("unexpected content for dir/large.bin")
+		}
+	})
+
+	t.Run("mismatch", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cab")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		expected := map[string]cab.FileExpectation{
+			"a.txt":         {Size: 2, SHA256: sha256Sum([]byte("a"))},
+			"dir/large.bin": {Size: -1, SHA256: sha256Sum([]byte("tampered"))},
+			"c.txt":         {Size: -1},
+		}
+		err = r.ExtractVerified(dir, expected)
+
+		var eerr *cab.ExpectationError
+		if !errors.As(err, &eerr) {
+			t.Fatalf("expected an ExpectationError, but got %v", err)
+		}
+
+		// hashes are only checked once the names and sizes match
+		var paths []string
+		for _, d := range eerr.Discrepancies {
+			paths = append(paths, d.Path)
+		}
+		if expected := []string{"a.txt", "b.txt", "c.txt"}; !reflect.DeepEqual(paths, expected) {
+			t.Fatalf("expected discrepancies for %v, but got %v", expected, eerr.Discrepancies)
+		}
+
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(entries) != 0 {
+			t.Fatalf("expected nothing to be written, but found %d entries", len(entries))
+		}
+	})
+
+	t.Run("hash mismatch", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "cab")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		expected := map[string]cab.FileExpectation{
+			"a.txt":         {Size: 1, SHA256: sha256Sum([]byte("a"))},
+			"dir/large.bin": {Size: -1, SHA256: sha256Sum([]byte("tampered"))},
+			"b.txt":         {Size: -1},
+		}
+		err = r.ExtractVerified(dir, expected)
+
+		var eerr *cab.ExpectationError
+		if !errors.As(err, &eerr) {
+			t.Fatalf("expected an ExpectationError, but got %v", err)
+		}
+		if len(eerr.Discrepancies) != 1 || eerr.Discrepancies[0].Path != "dir/large.bin" {
+			t.Fatalf("expected a discrepancy for dir/large.bin, but got %v", eerr.Discrepancies)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, "dir", "large.bin")); !os.IsNotExist(err) {
+			t.Fatalf("expected dir/large.bin to be removed, but got %v", err)
+		}
+		for _, name := range []string{"a.txt", "b.txt"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+				t.Fatalf("expected %s to be written, but got %v", name, err)
+			}
+		}
+	})
+}
+
+func TestExtractVerifiedKeepsExistingFile(t *testing.T) {
+	good, tampered := []byte("good contents"), []byte("evil contents")
+	data := buildMSZIPCab([]testFile{{name: `bin\app.bin`, data: tampered}, {name: "b.txt", data: []byte("b")}})
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "bin", "app.bin")
+	if err := os.Mkdir(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := ioutil.WriteFile(path, good, 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string]cab.FileExpectation{
+		"bin/app.bin": {Size: int64(len(good)), SHA256: sha256Sum(good)},
+		"b.txt":       {Size: -1, SHA256: sha256Sum([]byte("b"))},
+	}
+	var eerr *cab.ExpectationError
+	if err := r.ExtractVerified(dir, expected); !errors.As(err, &eerr) {
+		t.Fatalf("expected an ExpectationError, but got %v", err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(b, good) {
+		t.Fatalf("expected the existing file to be kept, but got %q", b)
+	}
+
+	// no temporary files are left behind, and verified files are moved into place
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only app.bin in bin, but found %d entries", len(entries))
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(dir, "b.txt")); err != nil || string(b) != "b" {
+		t.Fatalf("expected %q, but got %q and %v", "b", b, err)
+	}
+}
@@ -0,0 +1,42 @@
+package cab
+
+import (
+	"io"
+	"io/fs"
+)
+
+// SetCreateFile replaces the function used to create files during extraction and
+// returns a function that restores the original.
+func SetCreateFile(f func(name string, perm fs.FileMode) (io.WriteCloser, error)) (restore func()) {
+	orig := createFile
+	createFile = f
+	return func() { createFile = orig }
+}
+
+// EqualNames exposes equalNames.
+var EqualNames = equalNames
+
+// UnregisterDecompressor removes the decompressor registered for t.
+func UnregisterDecompressor(t CompressionType) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+
+	delete(decompressors, t)
+}
+
+// UnregisterCompressor removes the compressor registered for t.
+func UnregisterCompressor(t CompressionType) {
+	compressorsMu.Lock()
+	defer compressorsMu.Unlock()
+
+	delete(compressors, t)
+}
+
+// SetStripsTrailingDotsAndSpaces sets whether extraction behaves as on an operating
+// system that strips trailing dots and spaces from names, and returns a function that
+// restores the original.
+func SetStripsTrailingDotsAndSpaces(v bool) (restore func()) {
+	orig := stripsTrailingDotsAndSpaces
+	stripsTrailingDotsAndSpaces = v
+	return func() { stripsTrailingDotsAndSpaces = orig }
+}
@@ -0,0 +1,463 @@
+package cab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// createFile creates an output file with the given permissions during extraction. It
+// is a variable so tests can observe how many files are open at once.
+var createFile = func(name string, perm fs.FileMode) (io.WriteCloser, error) {
+	return os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+}
+
+// ExtractTo extracts every file in the cabinet into dir, recreating the directory
+// structure embedded in the file names. Folders are decompressed concurrently and
+// the number of output files open at any one time is bounded by WithMaxOpenFiles.
+// New files are created with the permissions given by File.Mode, or WithFileMode, and
+// new directories with DefaultDirMode, or WithDirMode, before the umask. Files are given
+// their DateTime as their modification time if WithRestoreModTimes is specified.
+//
+// On Windows, paths that exceed MAX_PATH are written using the `\\?\` extended-length
+// prefix. This only applies to files written to the operating system's file system.
+//
+// Unless WithAllowSpecialFiles is specified, files that may not be regular files are
+// refused: those with attributes the cabinet format does not define and those whose
+// names contain a colon, which Windows interprets as an alternate data stream.
+//
+// Names with elements ending in a dot or space are extracted as they are, except on
+// Windows, which would strip those characters, where they are refused. Use
+// WithTrimTrailingDotsAndSpaces to strip them explicitly on every platform.
+//
+// WithFlatten discards the directory structure, writing every file directly into dir.
+func (c *Reader) ExtractTo(dir string) error {
+	return c.ExtractToFunc(dir, nil)
+}
+
+// ExtractToFunc is like ExtractTo, but extracts only the files for which keep returns
+// true, such as those with particular attributes. A nil keep extracts every file.
+// Folders holding none of the kept files are not decompressed.
+func (c *Reader) ExtractToFunc(dir string, keep func(*File) bool) error {
+	return c.extractToFunc(dir, keep, nil)
+}
+
+// extractToFunc implements ExtractToFunc, checking the digests of the files in hc, if
+// it is not nil, as they are written.
+func (c *Reader) extractToFunc(dir string, keep func(*File) bool, hc *hashCheck) error {
+	if err := c.checkFiles(); err != nil {
+		return err
+	}
+	if c.opts.trimTrailing {
+		if _, err := c.trimmedCollisions(dir, keep); err != nil {
+			return err
+		}
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	if workers > len(c.Folders) {
+		workers = len(c.Folders)
+	}
+
+	sem := make(chan struct{}, c.opts.maxOpenFiles)
+	folders := make(chan *Folder)
+	errs := make([]error, workers)
+	skipped := make([][]*FolderError, workers)
+
+	m := c.newMetricsCollector()
+	defer c.reportMetrics(m)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for folder := range folders {
+				if errs[i] != nil {
+					continue
+				}
+				err := c.extractFolder(dir, folder, keep, sem, m, hc)
+				var fe *FolderError
+				if c.opts.skipUndecodable && errors.As(err, &fe) {
+					skipped[i] = append(skipped[i], fe)
+					continue
+				}
+				errs[i] = err
+			}
+		}(i)
+	}
+
+	for _, folder := range c.Folders {
+		folders <- folder
+	}
+	close(folders)
+	wg.Wait()
+
+	c.recordSkipped(skipped)
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// recordSkipped adds the folders skipped by an extraction, as collected by each of its
+// workers, to the Reader's warnings in folder order. A folder already skipped by an
+// earlier extraction is not recorded again.
+func (c *Reader) recordSkipped(skipped [][]*FolderError) {
+	var fes []*FolderError
+	for _, s := range skipped {
+		fes = append(fes, s...)
+	}
+	sort.Slice(fes, func(i, j int) bool { return fes[i].Folder < fes[j].Folder })
+
+	for _, fe := range fes {
+		if c.skippedFolders[fe.Folder] {
+			continue
+		}
+		if c.skippedFolders == nil {
+			c.skippedFolders = make(map[int]bool)
+		}
+		c.skippedFolders[fe.Folder] = true
+		c.warnings = append(c.warnings, fe)
+	}
+}
+
+// ExtractFile extracts the named file, found as by FileByName, into dir, recreating the
+// directories in its name, and returns the path written. Its name is checked and its
+// permissions and modification time are set as by ExtractTo. If no file has the name,
+// the error wraps fs.ErrNotExist.
+func (c *Reader) ExtractFile(name, dir string) (string, error) {
+	if err := c.checkFiles(); err != nil {
+		return "", err
+	}
+
+	file, ok := c.FileByName(name)
+	if !ok {
+		return "", &fs.PathError{Op: "extract", Path: name, Err: fs.ErrNotExist}
+	}
+
+	path, err := c.extractPath(dir, file)
+	if err != nil {
+		return "", err
+	}
+
+	if file.IsDir() {
+		return path, os.MkdirAll(longPath(path), c.opts.dirMode)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("cab: extracting %q: %w", file.DecodeName(), err)
+	}
+	defer rc.Close()
+
+	n, err := c.writeFile(path, file, rc, make(chan struct{}, 1), nil)
+	if err != nil {
+		return "", fmt.Errorf("cab: extracting %q: %w", file.DecodeName(), err)
+	}
+	if n != file.Size() {
+		return "", fmt.Errorf("cab: extracting %q: %w", file.DecodeName(), truncatedIfEOF(io.ErrUnexpectedEOF))
+	}
+
+	return path, nil
+}
+
+// PlannedWrite describes what ExtractTo would do for a single file in the cabinet.
+type PlannedWrite struct {
+	// Name is the file's name in the cabinet.
+	Name string
+	// Path is where the file would be written, or the empty string if its name is
+	// rejected.
+	Path string
+	// Size is the number of bytes that would be written.
+	Size int64
+	// IsDir reports whether a directory would be created instead of a file.
+	IsDir bool
+	// Overwrite reports whether a file already exists at Path and would be replaced.
+	Overwrite bool
+	// Err is the reason the file would not be extracted, such as a name that is
+	// absolute, escapes the destination directory or is refused as a special file, or
+	// one that would be written to the same Path as another once trimmed.
+	Err error
+}
+
+// ExtractToDryRun reports what ExtractTo would write into dir without writing
+// anything. It returns an entry for every file in the order of FilesSorted, including
+// those whose names would be rejected or, with WithTrimTrailingDotsAndSpaces, would
+// collide once trimmed. The returned error is the first such rejection, which ExtractTo
+// would also fail with, or an error inspecting dir.
+func (c *Reader) ExtractToDryRun(dir string) ([]PlannedWrite, error) {
+	if err := c.checkFiles(); err != nil {
+		return nil, err
+	}
+
+	var collisions map[*File]error
+	var firstErr error
+	if c.opts.trimTrailing {
+		// ExtractTo checks for collisions before writing anything
+		collisions, firstErr = c.trimmedCollisions(dir, nil)
+	}
+
+	files := c.FilesSorted()
+	plan := make([]PlannedWrite, 0, len(files))
+
+	for _, file := range files {
+		pw := PlannedWrite{
+			Name:  file.DecodeName(),
+			Size:  file.Size(),
+			IsDir: file.IsDir(),
+		}
+
+		path, err := c.extractPath(dir, file)
+		if err != nil {
+			pw.Err = err
+			if firstErr == nil {
+				firstErr = err
+			}
+			plan = append(plan, pw)
+			continue
+		}
+		pw.Path = path
+		pw.Err = collisions[file]
+
+		fi, err := os.Lstat(longPath(path))
+		switch {
+		case err == nil:
+			pw.Overwrite = !pw.IsDir && !fi.IsDir()
+		case !os.IsNotExist(err):
+			return nil, err
+		}
+
+		plan = append(plan, pw)
+	}
+
+	return plan, firstErr
+}
+
+// extractFolder writes the files of folder that keep accepts to dir, decompressing the
+// folder once and reading its files in offset order. Metrics are collected in m, and
+// the digests of the files in hc, if it is not nil, are checked as they are written.
+func (c *Reader) extractFolder(dir string, folder *Folder, keep func(*File) bool, sem chan struct{}, m *metricsCollector, hc *hashCheck) error {
+	files := make([]*File, 0, len(folder.Files))
+	for _, file := range folder.Files {
+		if keep == nil || keep(file) {
+			files = append(files, file)
+		}
+	}
+
+	return folder.readFiles(files, m, func(file *File, r io.Reader) error {
+		path, err := c.extractPath(dir, file)
+		if err != nil {
+			return err
+		}
+
+		if file.IsDir() {
+			if h := hc.hasher(file); h != nil {
+				hc.check(file, h)
+			}
+			return os.MkdirAll(longPath(path), c.opts.dirMode)
+		}
+
+		if _, err := c.writeFile(path, file, r, sem, hc); err != nil {
+			return fmt.Errorf("cab: extracting %q: %w", file.DecodeName(), err)
+		}
+		return nil
+	})
+}
+
+// writeFile writes the contents of file, read from r, to path. If hc checks the
+// file's digest, the contents are written to a temporary file beside path, which only
+// replaces path once the digest matches and is otherwise removed, so that neither a
+// file already at path is lost nor the wrong contents are ever found there.
+func (c *Reader) writeFile(path string, file *File, r io.Reader, sem chan struct{}, hc *hashCheck) (int64, error) {
+	path = longPath(path)
+	if err := os.MkdirAll(filepath.Dir(path), c.opts.dirMode); err != nil {
+		return 0, err
+	}
+
+	sem <- struct{}{}
+	defer func() { <-sem }()
+
+	mode := file.Mode()
+	if c.opts.setFileMode {
+		mode = c.opts.fileMode
+	}
+
+	h := hc.hasher(file)
+	name := path
+	var w io.WriteCloser
+	var err error
+	if h != nil {
+		w, name, err = createTemp(path, mode)
+	} else {
+		w, err = createFile(path, mode)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	var dst io.Writer = w
+	if h != nil {
+		dst = io.MultiWriter(w, h)
+	}
+	n, err := io.Copy(dst, r)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	if err == nil && c.opts.restoreModTimes && !file.DateTime.IsZero() {
+		err = os.Chtimes(name, file.DateTime, file.DateTime)
+	}
+
+	if h != nil {
+		if err == nil && hc.check(file, h) {
+			if err = os.Rename(name, path); err == nil {
+				return n, nil
+			}
+		}
+		os.Remove(name)
+	}
+
+	return n, err
+}
+
+// createTemp creates a new file with the given permissions in the directory of path,
+// named after it, returning it along with its name.
+func createTemp(path string, perm fs.FileMode) (io.WriteCloser, string, error) {
+	dir, base := filepath.Split(path)
+	for {
+		name := filepath.Join(dir, fmt.Sprintf(".%s.%d.tmp", base, rand.Uint32()))
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) {
+			continue
+		}
+		return f, name, err
+	}
+}
+
+// extractPath returns the path under dir at which file is extracted. Names that are
+// absolute or would escape dir are rejected, as are special files unless they are
+// allowed by WithAllowSpecialFiles.
+func (c *Reader) extractPath(dir string, file *File) (string, error) {
+	name := file.DecodeName()
+	attrs := file.Attributes()
+	if file.IsDir() {
+		name = name[:len(name)-1]
+		attrs &^= AttrDirectory
+	}
+
+	if !c.opts.allowSpecial {
+		if !attrs.IsRegular() {
+			return "", fmt.Errorf("cab: refusing to extract %q with attributes %#x", file.DecodeName(), uint16(file.Attributes()))
+		}
+		if strings.Contains(name, ":") {
+			return "", fmt.Errorf("cab: refusing to extract %q: name contains a colon", file.DecodeName())
+		}
+	}
+
+	parts := splitName(name)
+	for i, part := range parts {
+		if part == "" || part == "." || part == ".." || filepath.VolumeName(part) != "" {
+			return "", fmt.Errorf("cab: invalid file name %q", file.DecodeName())
+		}
+
+		trimmed := strings.TrimRight(part, ". ")
+		switch {
+		case trimmed == part:
+		case c.opts.trimTrailing:
+			if trimmed == "" {
+				return "", fmt.Errorf("cab: invalid file name %q", file.DecodeName())
+			}
+			parts[i] = trimmed
+		case stripsTrailingDotsAndSpaces:
+			return "", fmt.Errorf("cab: refusing to extract %q: name has an element ending in a dot or space", file.DecodeName())
+		}
+	}
+
+	if c.opts.folderPrefix {
+		dir = filepath.Join(dir, fmt.Sprintf("folder%d", file.folder.idx))
+	}
+	if c.opts.flatten {
+		if file.IsDir() {
+			return dir, nil
+		}
+		return filepath.Join(dir, c.flatName(file)), nil
+	}
+	return filepath.Join(append([]string{dir}, parts...)...), nil
+}
+
+// flatName returns the name under which file, which is not a directory, is extracted
+// by WithFlatten: the last element of its name, numbered if an earlier file has taken
+// it. The names of all files are chosen together, the first time one is needed.
+func (c *Reader) flatName(file *File) string {
+	c.flatOnce.Do(func() {
+		c.flatNames = make(map[*File]string, len(c.File))
+		taken := make(map[string]bool, len(c.File))
+		isTaken := func(key string) bool { return taken[key] }
+		for _, f := range c.File {
+			if f.IsDir() {
+				continue
+			}
+			parts := splitName(f.DecodeName())
+			name := parts[len(parts)-1]
+			if c.opts.trimTrailing {
+				name = strings.TrimRight(name, ". ")
+			}
+			if taken[mergeKey(name)] {
+				name = renameDuplicateFrom(name, 1, isTaken)
+			}
+			taken[mergeKey(name)] = true
+			c.flatNames[f] = name
+		}
+	})
+	return c.flatNames[file]
+}
+
+// stripsTrailingDotsAndSpaces reports whether the operating system removes the dots and
+// spaces ending the elements of a path when creating files.
+var stripsTrailingDotsAndSpaces = runtime.GOOS == "windows"
+
+// trimmedCollisions finds the files keep accepts that removing trailing dots and spaces
+// from their names would extract to the same path as a differently named file. It
+// returns the error for each such file, keyed by file, along with the first in stored
+// order, which is nil if there are none. Both files of a collision are included.
+func (c *Reader) trimmedCollisions(dir string, keep func(*File) bool) (map[*File]error, error) {
+	collisions := make(map[*File]error)
+	var first error
+	files := make(map[string]*File, len(c.File))
+	for _, file := range c.File {
+		if keep != nil && !keep(file) {
+			continue
+		}
+		path, err := c.extractPath(dir, file)
+		if err != nil {
+			// reported when the file is extracted
+			continue
+		}
+		if other, ok := files[path]; ok && other.Name != file.DecodeName() {
+			err := fmt.Errorf("cab: refusing to extract %q: it would overwrite %q", file.DecodeName(), other.Name)
+			collisions[file] = err
+			if _, ok := collisions[other]; !ok {
+				collisions[other] = err
+			}
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		files[path] = file
+	}
+	return collisions, first
+}
@@ -0,0 +1,774 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestExtractTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.OpenReader("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer r.Close()
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "README.md"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := "# gocab\r\nCab archive library for go.\r\n"
+	if string(b) != expected {
+		t.Fatalf("expected %q, but got %q", expected, string(b))
+	}
+}
+
+func TestExtractToMaxOpenFiles(t *testing.T) {
+	const maxOpenFiles = 3
+
+	// ensure there are more folder workers than the budget allows open files
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(8))
+
+	var folders [][]testFile
+	for i := 0; i < 40; i++ {
+		var files []testFile
+		for j := 0; j < 50; j++ {
+			files = append(files, testFile{
+				name: fmt.Sprintf(`folder%d\file%d.txt`, i, j),
+				data: []byte(fmt.Sprintf("%d-%d", i, j)),
+			})
+		}
+		folders = append(folders, files)
+	}
+	data := buildCab(folders...)
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex
+	var open, maxOpen int
+	restore := cab.SetCreateFile(func(name string, perm fs.FileMode) (io.WriteCloser, error) {
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		open++
+		if open > maxOpen {
+			maxOpen = open
+		}
+		mu.Unlock()
+
+		return &countingFile{File: f, mu: &mu, open: &open}, nil
+	})
+	defer restore()
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithMaxOpenFiles(maxOpenFiles))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if maxOpen > maxOpenFiles {
+		t.Fatalf("expected at most %d open file(s), but got %d", maxOpenFiles, maxOpen)
+	}
+
+	for i := range folders {
+		for j, f := range folders[i] {
+			b, err := ioutil.ReadFile(filepath.Join(dir, fmt.Sprintf("folder%d", i), fmt.Sprintf("file%d.txt", j)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if !bytes.Equal(b, f.data) {
+				t.Fatalf("expected %q, but got %q", f.data, b)
+			}
+		}
+	}
+}
+
+func TestExtractToRejectsTraversal(t *testing.T) {
+	data := buildCab([]testFile{{name: `..\evil.txt`, data: []byte("evil")}})
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(filepath.Join(dir, "out")); err == nil {
+		t.Fatalf("expected an error, but got none")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected evil.txt to not exist, but got %v", err)
+	}
+}
+
+type countingFile struct {
+	*os.File
+	mu   *sync.Mutex
+	open *int
+}
+
+func (f *countingFile) Close() error {
+	f.mu.Lock()
+	*f.open--
+	f.mu.Unlock()
+	return f.File.Close()
+}
+
+func TestExtractToEmptyFiles(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: "first.txt"},
+		{name: "data.txt", data: []byte("data")},
+		{name: "last.txt"},
+	})
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{"first.txt": "", "data.txt": "data", "last.txt": ""}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != content {
+			t.Fatalf("expected %q for %s, but got %q", content, name, b)
+		}
+	}
+}
+
+func TestExtractToDryRun(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: "a.txt", data: []byte("aaa")},
+		{name: `sub\b.txt`, data: []byte("b")},
+		{name: `docs\`},
+		{name: `..\evil.txt`, data: []byte("evil")},
+	})
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("old"), 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	plan, err := r.ExtractToDryRun(dir)
+	if err == nil {
+		t.Fatalf("expected an error, but got none")
+	}
+
+	if len(plan) != 4 {
+		t.Fatalf("expected 4 planned writes, but got %d", len(plan))
+	}
+
+	if pw := plan[0]; pw.Name != `..\evil.txt` || pw.Err == nil || pw.Path != "" {
+		t.Fatalf("expected %q to be rejected, but got %+v", `..\evil.txt`, pw)
+	}
+
+	expected := []cab.PlannedWrite{
+		{Name: "a.txt", Path: filepath.Join(dir, "a.txt"), Size: 3, Overwrite: true},
+		{Name: `docs\`, Path: filepath.Join(dir, "docs"), IsDir: true},
+		{Name: `sub\b.txt`, Path: filepath.Join(dir, "sub", "b.txt"), Size: 1},
+	}
+	for i, e := range expected {
+		if plan[i+1] != e {
+			t.Fatalf("expected %+v, but got %+v", e, plan[i+1])
+		}
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected nothing to be written, but found %d entries", len(entries))
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dir, "a.txt")); string(b) != "old" {
+		t.Fatalf("expected a.txt to be unchanged, but got %q", b)
+	}
+}
+
+func TestExtractToDryRunTrimmedCollision(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: "a.txt", data: []byte("first")},
+		{name: "a.txt.", data: []byte("second")},
+		{name: "b.txt", data: []byte("b")},
+	})
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithTrimTrailingDotsAndSpaces())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	plan, err := r.ExtractToDryRun(dir)
+	if err == nil || !strings.Contains(err.Error(), "overwrite") {
+		t.Fatalf("expected an error about overwriting, but got %v", err)
+	}
+	extractErr := r.ExtractTo(dir)
+	if extractErr == nil || extractErr.Error() != err.Error() {
+		t.Fatalf("expected ExtractTo to fail with %v, but got %v", err, extractErr)
+	}
+
+	for _, pw := range plan {
+		if collides := pw.Name != "b.txt"; (pw.Err != nil) != collides {
+			t.Fatalf("expected an error for %q only if it collides, but got %v", pw.Name, pw.Err)
+		}
+		if pw.Path != filepath.Join(dir, pw.Name[:5]) {
+			t.Fatalf("unexpected path %s for %q", pw.Path, pw.Name)
+		}
+	}
+}
+
+func TestExtractToAttributeMapper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on windows")
+	}
+
+	data := buildCab([]testFile{
+		{name: "plain.txt", data: []byte("plain")},
+		{name: "readonly.txt", data: []byte("readonly")},
+		{name: "run.sh", data: []byte("run")},
+	})
+	// attribs of the second and third files
+	binary.LittleEndian.PutUint16(data[84:], uint16(cab.AttrReadOnly))
+	binary.LittleEndian.PutUint16(data[113:], uint16(cab.AttrExec))
+
+	testCases := []struct {
+		name     string
+		opts     []cab.ReaderOption
+		expected map[string]fs.FileMode
+	}{
+		{
+			name:     "default",
+			expected: map[string]fs.FileMode{"plain.txt": 0644, "readonly.txt": 0444, "run.sh": 0755},
+		},
+		{
+			name: "mapper",
+			opts: []cab.ReaderOption{cab.WithAttributeMapper(func(cab.FileAttributes) fs.FileMode {
+				return 0600
+			})},
+			expected: map[string]fs.FileMode{"plain.txt": 0600, "readonly.txt": 0600, "run.sh": 0600},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cab")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), tc.opts...)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			if err := r.ExtractTo(dir); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			for name, expected := range tc.expected {
+				file, ok := r.FileByName(name)
+				if !ok {
+					t.Fatalf("expected to find %s", name)
+				}
+				if file.Mode() != expected {
+					t.Fatalf("expected mode %v for %s, but got %v", expected, name, file.Mode())
+				}
+
+				fi, err := os.Stat(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				// the umask may only remove group and other write permission
+				if actual := fi.Mode().Perm(); actual&^0022 != expected&^0022 || actual&^expected != 0 {
+					t.Fatalf("expected permissions %v for %s, but got %v", expected, name, actual)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractToDirAndFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permission bits are not meaningful on windows")
+	}
+
+	data := buildCab([]testFile{
+		{name: `dir\sub\a.txt`, data: []byte("a")},
+		{name: `empty\`},
+	})
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithDirMode(0700), cab.WithFileMode(0600))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	// neither mode has permissions the umask could remove
+	expected := map[string]fs.FileMode{
+		"dir":                                fs.ModeDir | 0700,
+		filepath.Join("dir", "sub"):          fs.ModeDir | 0700,
+		filepath.Join("dir", "sub", "a.txt"): 0600,
+		"empty":                              fs.ModeDir | 0700,
+	}
+	for name, mode := range expected {
+		fi, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if fi.Mode() != mode {
+			t.Fatalf("expected mode %v for %s, but got %v", mode, name, fi.Mode())
+		}
+	}
+
+	if mode := r.File[0].Mode(); mode != 0644 {
+		t.Fatalf("expected File.Mode to be unaffected, but got %v", mode)
+	}
+
+	path, err := r.ExtractFile("dir/sub/a.txt", filepath.Join(dir, "single"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	for name, mode := range map[string]fs.FileMode{path: 0600, filepath.Dir(path): fs.ModeDir | 0700} {
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if fi.Mode() != mode {
+			t.Fatalf("expected mode %v for %s, but got %v", mode, name, fi.Mode())
+		}
+	}
+}
+
+func TestExtractToRejectsSpecialFiles(t *testing.T) {
+	testCases := []struct {
+		name  string
+		file  string
+		attrs cab.FileAttributes
+	}{
+		{name: "alternate data stream", file: "file.txt:stream"},
+		{name: "device", file: `sub\CON:`},
+		{name: "directory attribute", file: "file.txt", attrs: cab.AttrDirectory},
+		{name: "volume label", file: "label", attrs: cab.AttrVolumeLabel},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildCab([]testFile{{name: tc.file, data: []byte("special")}})
+			// attribs of the first file
+			binary.LittleEndian.PutUint16(data[58:], uint16(tc.attrs))
+
+			for _, allow := range []bool{false, true} {
+				dir, err := ioutil.TempDir("", "cab")
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				defer os.RemoveAll(dir)
+
+				var opts []cab.ReaderOption
+				if allow {
+					opts = append(opts, cab.WithAllowSpecialFiles())
+				}
+				r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), opts...)
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+
+				plan, dryRunErr := r.ExtractToDryRun(dir)
+				err = r.ExtractTo(dir)
+				if allow {
+					// a colon can't be written on Windows regardless
+					if runtime.GOOS == "windows" && strings.Contains(tc.file, ":") {
+						continue
+					}
+					if err != nil || dryRunErr != nil || plan[0].Err != nil {
+						t.Fatalf("expected no error when allowed, but got %v", err)
+					}
+					continue
+				}
+
+				if err == nil || dryRunErr == nil || plan[0].Err == nil {
+					t.Fatalf("expected an error, but got none")
+				}
+				entries, err := ioutil.ReadDir(dir)
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if len(entries) != 0 {
+					t.Fatalf("expected nothing to be written, but found %d entries", len(entries))
+				}
+			}
+		})
+	}
+}
+
+func TestExtractToDirectoryAttribute(t *testing.T) {
+	data := buildCab([]testFile{{name: `docs\`}})
+	// attribs of the first file
+	binary.LittleEndian.PutUint16(data[58:], uint16(cab.AttrDirectory))
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if fi, err := os.Stat(filepath.Join(dir, "docs")); err != nil || !fi.IsDir() {
+		t.Fatalf("expected docs to be a directory, but got %v", err)
+	}
+}
+
+func TestExtractToFunc(t *testing.T) {
+	data := buildCab(
+		[]testFile{
+			{name: "plain.txt", data: []byte("plain")},
+			{name: "hidden.txt", data: []byte("hidden")},
+			{name: "run.sh", data: []byte("run")},
+		},
+		[]testFile{{name: "other.txt", data: []byte("other")}},
+	)
+	// attribs of the second and third files
+	binary.LittleEndian.PutUint16(data[92:], uint16(cab.AttrHidden))
+	binary.LittleEndian.PutUint16(data[119:], uint16(cab.AttrExec))
+	// the second folder's data is unreadable, so it must not be decompressed
+	binary.LittleEndian.PutUint32(data[44:], uint32(len(data)))
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	err = r.ExtractToFunc(dir, func(file *cab.File) bool {
+		return file.Attributes()&cab.AttrExec != 0
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "run.sh" {
+		t.Fatalf("expected only run.sh to be extracted, but found %d entries", len(entries))
+	}
+}
+
+func TestExtractFile(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: "a.txt", data: []byte("a")},
+		{name: `sub\deeper\b.txt`, data: []byte("bb")},
+	})
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	path, err := r.ExtractFile("SUB/deeper/B.TXT", dir)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if expected := filepath.Join(dir, "sub", "deeper", "b.txt"); path != expected {
+		t.Fatalf("expected %s, but got %s", expected, path)
+	}
+	if b, err := ioutil.ReadFile(path); err != nil || string(b) != "bb" {
+		t.Fatalf("expected %q, but got %q and %v", "bb", b, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected only the named file to be extracted, but got %v", err)
+	}
+
+	if _, err := r.ExtractFile("missing.txt", dir); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected %v, but got %v", fs.ErrNotExist, err)
+	}
+}
+
+func TestExtractToTrailingDotsAndSpaces(t *testing.T) {
+	files := []testFile{
+		{name: "foo.", data: []byte("foo")},
+		{name: `dir \bar `, data: []byte("bar")},
+		{name: `dir \baz.txt`, data: []byte("baz")},
+	}
+
+	extract := func(t *testing.T, files []testFile, opts ...cab.ReaderOption) (string, error) {
+		dir, err := ioutil.TempDir("", "cab")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		data := buildCab(files)
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), opts...)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return dir, r.ExtractTo(dir)
+	}
+
+	check := func(t *testing.T, dir string, expected map[string]string) {
+		for name, content := range expected {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if string(b) != content {
+				t.Fatalf("expected %q for %s, but got %q", content, name, b)
+			}
+		}
+	}
+
+	t.Run("preserved", func(t *testing.T) {
+		if runtime.GOOS == "windows" {
+			t.Skip("Windows cannot create names ending in a dot or space")
+		}
+		defer cab.SetStripsTrailingDotsAndSpaces(false)()
+
+		dir, err := extract(t, files)
+		defer os.RemoveAll(dir)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		check(t, dir, map[string]string{
+			"foo.":                           "foo",
+			filepath.Join("dir ", "bar "):    "bar",
+			filepath.Join("dir ", "baz.txt"): "baz",
+		})
+	})
+
+	t.Run("refused where stripped", func(t *testing.T) {
+		defer cab.SetStripsTrailingDotsAndSpaces(true)()
+
+		dir, err := extract(t, files)
+		defer os.RemoveAll(dir)
+		if err == nil || !strings.Contains(err.Error(), "dot or space") {
+			t.Fatalf("expected an error about a trailing dot or space, but got %v", err)
+		}
+	})
+
+	t.Run("trimmed", func(t *testing.T) {
+		dir, err := extract(t, files, cab.WithTrimTrailingDotsAndSpaces())
+		defer os.RemoveAll(dir)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		check(t, dir, map[string]string{
+			"foo":                           "foo",
+			filepath.Join("dir", "bar"):     "bar",
+			filepath.Join("dir", "baz.txt"): "baz",
+		})
+	})
+
+	t.Run("trimmed collision", func(t *testing.T) {
+		dir, err := extract(t, []testFile{
+			{name: "foo", data: []byte("first")},
+			{name: "foo. ", data: []byte("second")},
+		}, cab.WithTrimTrailingDotsAndSpaces())
+		defer os.RemoveAll(dir)
+		if err == nil || !strings.Contains(err.Error(), "overwrite") {
+			t.Fatalf("expected an error about overwriting, but got %v", err)
+		}
+	})
+
+	t.Run("trimmed to nothing", func(t *testing.T) {
+		dir, err := extract(t, []testFile{{name: `. .\foo`, data: []byte("foo")}}, cab.WithTrimTrailingDotsAndSpaces())
+		defer os.RemoveAll(dir)
+		if err == nil || !strings.Contains(err.Error(), "invalid file name") {
+			t.Fatalf("expected an invalid file name error, but got %v", err)
+		}
+	})
+}
+
+func TestExtractToFlatten(t *testing.T) {
+	data := buildMSZIPCab(
+		[]testFile{
+			{name: `docs\readme.txt`, data: []byte("docs")},
+			{name: `empty\`},
+			{name: `src\lib\readme.txt`, data: []byte("lib")},
+		},
+		[]testFile{{name: "a.txt", data: []byte("a")}, {name: `tools\README.TXT`, data: []byte("tools")}},
+	)
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithFlatten())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{
+		"readme.txt":     "docs",
+		"readme (1).txt": "lib",
+		"a.txt":          "a",
+		"README (2).TXT": "tools",
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d files, but got %d", len(expected), len(entries))
+	}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != content {
+			t.Fatalf("expected %q for %s, but got %q", content, name, b)
+		}
+	}
+
+	plan, err := r.ExtractToDryRun(dir)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	for _, pw := range plan {
+		if pw.Name == `tools\README.TXT` && pw.Path != filepath.Join(dir, "README (2).TXT") {
+			t.Fatalf("expected the dry run to match, but got %s", pw.Path)
+		}
+	}
+}
+
+func TestExtractToFolderPrefixDirs(t *testing.T) {
+	data := buildMSZIPCab(
+		[]testFile{{name: "same.txt", data: []byte("first")}, {name: `dir\a.txt`, data: []byte("a")}},
+		[]testFile{{name: "same.txt", data: []byte("second")}},
+	)
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithFolderPrefixDirs())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{
+		filepath.Join("folder0", "same.txt"):     "first",
+		filepath.Join("folder0", "dir", "a.txt"): "a",
+		filepath.Join("folder1", "same.txt"):     "second",
+	}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != content {
+			t.Fatalf("expected %q for %s, but got %q", content, name, b)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "same.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected nothing outside the folder directories, but got %v", err)
+	}
+}
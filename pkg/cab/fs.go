@@ -0,0 +1,197 @@
+package cab
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+var _ fs.FS = (*Reader)(nil)
+
+// Open opens the named file in the cabinet, using the semantics of fs.FS.Open: paths
+// are always slash separated, with no leading slash or dot elements, and are matched
+// exactly. Cabinet names, which use backslashes, are presented with slashes, and the
+// directories implied by them can be opened and read with ReadDir. Files whose names
+// are not valid paths, or that would be a directory of another file, cannot be opened.
+func (c *Reader) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if err := c.checkFiles(); err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	e := c.fsIndex()[name]
+	if e == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	if e.isDir {
+		return &openDir{e: e}, nil
+	}
+
+	rc, err := e.file.Open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &openFile{e: e, rc: rc}, nil
+}
+
+// fsEntry is a file or directory in the tree presented by Reader.Open.
+type fsEntry struct {
+	name     string // the last element of the path
+	isDir    bool
+	file     *File // the file, or directory marker, if there is one in the cabinet
+	children []*fsEntry
+}
+
+func (e *fsEntry) stat() fs.FileInfo {
+	if e.file != nil {
+		return e.file.FileInfo()
+	}
+	return dirInfo{name: e.name}
+}
+
+// fsIndex returns the entries of the tree presented by Open, by path. It is built the
+// first time it is needed.
+func (c *Reader) fsIndex() map[string]*fsEntry {
+	c.fsOnce.Do(func() {
+		entries := map[string]*fsEntry{".": {name: ".", isDir: true}}
+
+		// dir returns the directory entry at name, creating it and its parents if
+		// needed, or nil if a file is in the way
+		var dir func(name string) *fsEntry
+		dir = func(name string) *fsEntry {
+			if e, ok := entries[name]; ok {
+				if !e.isDir {
+					return nil
+				}
+				return e
+			}
+
+			parent := dir(path.Dir(name))
+			if parent == nil {
+				return nil
+			}
+			e := &fsEntry{name: path.Base(name), isDir: true}
+			entries[name] = e
+			parent.children = append(parent.children, e)
+			return e
+		}
+
+		for _, file := range c.File {
+			name := normalizeName(file.DecodeName())
+			if file.IsDir() {
+				name = name[:len(name)-1]
+			}
+			if !fs.ValidPath(name) || name == "." {
+				continue
+			}
+
+			if file.IsDir() {
+				if e := dir(name); e != nil && e.file == nil {
+					e.file = file
+				}
+				continue
+			}
+
+			if _, ok := entries[name]; ok {
+				continue
+			}
+			parent := dir(path.Dir(name))
+			if parent == nil {
+				continue
+			}
+			e := &fsEntry{name: path.Base(name), file: file}
+			entries[name] = e
+			parent.children = append(parent.children, e)
+		}
+
+		for _, e := range entries {
+			sort.Slice(e.children, func(i, j int) bool {
+				return e.children[i].name < e.children[j].name
+			})
+		}
+
+		c.fsEntries = entries
+	})
+
+	return c.fsEntries
+}
+
+// openFile is a regular file opened by Reader.Open.
+type openFile struct {
+	e  *fsEntry
+	rc io.ReadCloser
+}
+
+func (f *openFile) Stat() (fs.FileInfo, error) { return f.e.stat(), nil }
+func (f *openFile) Read(p []byte) (int, error) { return f.rc.Read(p) }
+func (f *openFile) Close() error               { return f.rc.Close() }
+
+// openDir is a directory opened by Reader.Open.
+type openDir struct {
+	e   *fsEntry
+	off int // the number of entries already returned by ReadDir
+}
+
+func (d *openDir) Stat() (fs.FileInfo, error) { return d.e.stat(), nil }
+func (d *openDir) Close() error               { return nil }
+
+func (d *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.e.name, Err: errors.New("is a directory")}
+}
+
+func (d *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	children := d.e.children[d.off:]
+	if n > 0 && n < len(children) {
+		children = children[:n]
+	}
+	if n > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+
+	entries := make([]fs.DirEntry, len(children))
+	for i, child := range children {
+		entries[i] = fs.FileInfoToDirEntry(child.stat())
+	}
+	d.off += len(children)
+	return entries, nil
+}
+
+// FileInfo returns an fs.FileInfo describing the file. Its name is the last element of
+// the file's path and Sys returns the *File.
+func (f *File) FileInfo() fs.FileInfo {
+	return fileInfo{f: f}
+}
+
+type fileInfo struct {
+	f *File
+}
+
+func (fi fileInfo) Name() string {
+	name := strings.TrimRight(normalizeName(fi.f.DecodeName()), "/")
+	return path.Base(name)
+}
+
+func (fi fileInfo) Size() int64        { return fi.f.Size() }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.f.Mode() }
+func (fi fileInfo) ModTime() time.Time { return fi.f.DateTime }
+func (fi fileInfo) IsDir() bool        { return fi.f.IsDir() }
+func (fi fileInfo) Sys() interface{}   { return fi.f }
+
+// dirInfo describes a directory implied by the names of the files in a cabinet.
+type dirInfo struct {
+	name string
+}
+
+func (di dirInfo) Name() string       { return di.name }
+func (di dirInfo) Size() int64        { return 0 }
+func (di dirInfo) Mode() fs.FileMode  { return fs.ModeDir | 0755 }
+func (di dirInfo) ModTime() time.Time { return time.Time{} }
+func (di dirInfo) IsDir() bool        { return true }
+func (di dirInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,157 @@
+package cab_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderFS(t *testing.T) {
+	data := buildCab(
+		[]testFile{
+			{name: "a.txt", data: []byte("a")},
+			{name: `sub\b.txt`, data: []byte("bb")},
+		},
+		[]testFile{
+			{name: `sub\deeper\c.txt`, data: []byte("ccc")},
+			{name: `empty\`},
+			{name: `..\evil.txt`, data: []byte("evil")},
+		},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := fstest.TestFS(r, "a.txt", "sub/b.txt", "sub/deeper/c.txt", "empty"); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	b, err := fs.ReadFile(r, "sub/deeper/c.txt")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if string(b) != "ccc" {
+		t.Fatalf("expected %q, but got %q", "ccc", b)
+	}
+
+	entries, err := fs.ReadDir(r, ".")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if expected := "[a.txt empty sub]"; fmt.Sprint(names) != expected {
+		t.Fatalf("expected %s, but got %v", expected, names)
+	}
+}
+
+func TestReaderFSPaths(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: `dir\sub\c.txt`, data: []byte("ccc")},
+		{name: `dir\b.txt`, data: []byte("bb")},
+	})
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	b, err := fs.ReadFile(r, "dir/sub/c.txt")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if string(b) != "ccc" {
+		t.Fatalf("expected %q, but got %q", "ccc", b)
+	}
+
+	fi, err := fs.Stat(r, "dir/sub/c.txt")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if fi.Name() != "c.txt" || fi.Size() != 3 || fi.IsDir() {
+		t.Fatalf("unexpected file info %v %d %v", fi.Name(), fi.Size(), fi.IsDir())
+	}
+
+	// dir has no directory marker in the cabinet; it is implied by the file names
+	fi, err = fs.Stat(r, "dir")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if fi.Name() != "dir" || !fi.IsDir() {
+		t.Fatalf("expected dir to be a directory, but got %v %v", fi.Name(), fi.IsDir())
+	}
+
+	entries, err := fs.ReadDir(r, "dir")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, fmt.Sprintf("%s:%v", e.Name(), e.IsDir()))
+	}
+	if expected := "[b.txt:false sub:true]"; fmt.Sprint(names) != expected {
+		t.Fatalf("expected %s, but got %v", expected, names)
+	}
+
+	// backslashes are ordinary characters in fs paths, so the cabinet's own names do
+	// not match
+	if _, err := r.Open(`dir\sub\c.txt`); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, but got %v", err)
+	}
+
+	for _, name := range []string{"../dir/b.txt", "dir/../dir/b.txt", "/dir/b.txt", "./dir/b.txt", "dir/", ""} {
+		if _, err := r.Open(name); !errors.Is(err, fs.ErrInvalid) {
+			t.Fatalf("expected fs.ErrInvalid opening %q, but got %v", name, err)
+		}
+		if _, err := fs.Stat(r, name); !errors.Is(err, fs.ErrInvalid) {
+			t.Fatalf("expected fs.ErrInvalid from Stat of %q, but got %v", name, err)
+		}
+		if _, err := fs.ReadDir(r, name); !errors.Is(err, fs.ErrInvalid) {
+			t.Fatalf("expected fs.ErrInvalid from ReadDir of %q, but got %v", name, err)
+		}
+	}
+}
+
+func TestReaderFile(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: "b.txt", data: []byte("b")}},
+		[]testFile{{name: `dir\a.txt`, data: []byte("aa")}},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if len(r.File) != 2 || r.File[0].Name != "b.txt" || r.File[1].Name != `dir\a.txt` {
+		t.Fatalf("expected the files in stored order, but got %v", r.File)
+	}
+
+	rc, err := r.File[1].Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer rc.Close()
+	if b, err := ioutil.ReadAll(rc); err != nil || string(b) != "aa" {
+		t.Fatalf("expected %q, but got %q and %v", "aa", b, err)
+	}
+
+	fi := r.File[1].FileInfo()
+	if fi.Name() != "a.txt" || fi.Size() != 2 || fi.IsDir() || fi.Mode() != 0644 || !fi.ModTime().Equal(time.Time{}) {
+		t.Fatalf("unexpected file info %v %d %v %v %v", fi.Name(), fi.Size(), fi.IsDir(), fi.Mode(), fi.ModTime())
+	}
+	if fi.Sys() != r.File[1] {
+		t.Fatalf("expected Sys to return the file")
+	}
+}
@@ -0,0 +1,68 @@
+//go:build go1.18
+// +build go1.18
+
+package cab_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+// FuzzReader opens arbitrary bytes as a cabinet and, if that succeeds, reads
+// everything it describes. Errors are expected; panics are not. The seeds cover the
+// structures the parser walks: testdata/readme.cab, stored and MSZIP cabinets with
+// several folders, a volume linked to both neighbours, a cabinet with reserved areas
+// and one with a file split across volumes.
+func FuzzReader(f *testing.F) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		f.Fatalf("expected no error, but got %v", err)
+	}
+	folders := [][]testFile{
+		{{name: `dir\a.txt`, data: []byte("a")}, {name: `empty\`}},
+		{{name: "b.txt", data: bytes.Repeat([]byte("b"), 100)}},
+	}
+
+	f.Add(readme)
+	f.Add(buildCab(folders...))
+	f.Add(buildMSZIPCab(folders...))
+	f.Add(linkVolume(buildCab(folders...), true, true))
+	f.Add(withDataReserve(buildCab(folders...), 4))
+	f.Add(buildRawCab(0x3, 1, []rawFile{{name: "big.bin", size: 100, folder: 0xffff}}, []rawBlock{
+		{data: []byte("CK\x4b\x04\x00"), uncompressedSize: 0},
+	}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return
+		}
+
+		r.Manifest()
+		r.UnreferencedRegions()
+		r.MemoryEstimate()
+		r.VerifyChecksums()
+		for _, folder := range r.Folders {
+			folder.Blocks()
+		}
+		for _, file := range r.File {
+			rc, err := file.Open()
+			if err != nil {
+				continue
+			}
+			io.Copy(ioutil.Discard, rc)
+			rc.Close()
+
+			file.ReadRange(0, 16)
+		}
+		cab.NewSetReader(r)
+		r.Open(".")
+		r.ToZip(ioutil.Discard)
+		cab.Repack(ioutil.Discard, r, cab.WithCompression(cab.CompressionMSZIP))
+		r.IterateSet(func(*cab.Ref) (*cab.Reader, error) { return r, nil }, func(*cab.File) error { return nil })
+	})
+}
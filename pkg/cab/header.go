@@ -0,0 +1,114 @@
+package cab
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+)
+
+// FileHeader describes a file in a cabinet, as archive/zip's FileHeader does for a zip
+// archive. It is returned by File.FileHeader and passed to Writer.CreateHeader.
+type FileHeader struct {
+	// Name is the file's name, a relative path. Cabinets separate its elements with
+	// backslashes; CreateHeader also accepts forward slashes. A name ending in a
+	// separator is a directory.
+	Name string
+	// Modified is the file's modification time, which cabinets store at a resolution
+	// of two seconds, without a time zone.
+	Modified time.Time
+	// Attributes are the file's attribute flags. AttrNameIsUTF is set, and ignored by
+	// CreateHeader, according to whether Name is ASCII.
+	Attributes FileAttributes
+	// UncompressedSize64 is the size of the file's contents. CreateHeader ignores it;
+	// the size is that of the data written.
+	UncompressedSize64 uint64
+}
+
+// Mode returns the permission bits derived from the header's attributes by
+// FileAttributes.Mode, with fs.ModeDir set if the header describes a directory.
+func (fh *FileHeader) Mode() fs.FileMode {
+	mode := fh.Attributes.Mode()
+	if hasTrailingSeparator(fh.Name) {
+		mode |= fs.ModeDir
+	}
+	return mode
+}
+
+// FileInfoHeader returns a FileHeader describing fi, as archive/zip's FileInfoHeader
+// does. Its Name is only the base name of the file, which the caller may need to
+// change to the file's full path. AttrReadOnly is set if fi's mode lacks owner write
+// permission, and AttrExec if it has any execute permission and is not a directory.
+func FileInfoHeader(fi fs.FileInfo) (*FileHeader, error) {
+	mode := fi.Mode()
+	if !mode.IsRegular() && !mode.IsDir() {
+		return nil, errors.New("cab: only regular files and directories can be added")
+	}
+
+	fh := &FileHeader{
+		Name:     fi.Name(),
+		Modified: fi.ModTime(),
+	}
+	if mode.IsDir() {
+		fh.Name += `\`
+	} else {
+		fh.UncompressedSize64 = uint64(fi.Size())
+		if mode&0111 != 0 {
+			fh.Attributes |= AttrExec
+		}
+	}
+	if mode&0200 == 0 {
+		fh.Attributes |= AttrReadOnly
+	}
+	return fh, nil
+}
+
+// FileHeader returns a FileHeader describing the file.
+func (f *File) FileHeader() FileHeader {
+	return FileHeader{
+		Name:               f.DecodeName(),
+		Modified:           f.DateTime,
+		Attributes:         f.Attributes(),
+		UncompressedSize64: uint64(f.uncompressedSize),
+	}
+}
+
+// CreateHeader adds a file described by fh to the cabinet, as Create does, and returns
+// a Writer to which its contents should be written. The file is given fh's modification
+// time and attributes. Forward slashes in fh.Name are converted to backslashes. If the
+// name ends in a separator, the file is a directory, as added by AddDir, and the Writer
+// accepts no data.
+func (w *Writer) CreateHeader(fh *FileHeader) (io.Writer, error) {
+	name := strings.ReplaceAll(fh.Name, "/", `\`)
+	isDir := hasTrailingSeparator(name)
+	if isDir {
+		name = strings.TrimRight(name, `\`)
+		if name == "" {
+			return nil, errors.New("cab: invalid directory name")
+		}
+		name += `\`
+	}
+
+	f, err := w.addFile(name)
+	if err != nil {
+		return nil, err
+	}
+	f.date, f.time = timeToMsDosTime(fh.Modified)
+	f.attributes |= uint16(fh.Attributes &^ AttrNameIsUTF)
+
+	if isDir {
+		return dirWriter{}, nil
+	}
+	return &fileWriter{w: w, f: f}, nil
+}
+
+// dirWriter is returned by CreateHeader for a directory, which has no contents.
+type dirWriter struct{}
+
+func (dirWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		return 0, errors.New("cab: write to directory")
+	}
+	return 0, nil
+}
@@ -0,0 +1,115 @@
+package cab_test
+
+import (
+	"bytes"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestWriterCreateHeader(t *testing.T) {
+	modified := time.Date(2021, 3, 14, 15, 9, 27, 0, time.UTC)
+
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf)
+	fw, err := w.CreateHeader(&cab.FileHeader{
+		Name:       "dir/a.txt",
+		Modified:   modified,
+		Attributes: cab.AttrReadOnly | cab.AttrNameIsUTF,
+	})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := fw.Write([]byte("hello")); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	dw, err := w.CreateHeader(&cab.FileHeader{Name: "empty/", Modified: modified})
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := dw.Write([]byte("x")); err == nil {
+		t.Fatalf("expected an error writing to a directory")
+	}
+	if _, err := w.CreateHeader(&cab.FileHeader{Name: "/"}); err == nil {
+		t.Fatalf("expected an error for an empty directory name")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if len(r.File) != 2 {
+		t.Fatalf("expected 2 files, but got %d", len(r.File))
+	}
+
+	fh := r.File[0].FileHeader()
+	// the odd second is lost to the two second resolution
+	expected := cab.FileHeader{
+		Name:               `dir\a.txt`,
+		Modified:           modified.Add(-time.Second),
+		Attributes:         cab.AttrReadOnly,
+		UncompressedSize64: 5,
+	}
+	if fh.Name != expected.Name || !fh.Modified.Equal(expected.Modified) || fh.Attributes != expected.Attributes || fh.UncompressedSize64 != expected.UncompressedSize64 {
+		t.Fatalf("expected %+v, but got %+v", expected, fh)
+	}
+	if fh.Mode() != 0444 {
+		t.Fatalf("expected mode %v, but got %v", fs.FileMode(0444), fh.Mode())
+	}
+	if b := readFile(t, r.File[0]); string(b) != "hello" {
+		t.Fatalf("expected %q, but got %q", "hello", b)
+	}
+
+	fh = r.File[1].FileHeader()
+	if fh.Name != `empty\` || !fh.Mode().IsDir() || fh.UncompressedSize64 != 0 {
+		t.Fatalf("expected a directory, but got %+v", fh)
+	}
+}
+
+func TestFileInfoHeader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "tool.sh")
+	if err := ioutil.WriteFile(name, []byte("#!/bin/sh\n"), 0555); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	fh, err := cab.FileInfoHeader(fi)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if fh.Name != "tool.sh" || fh.UncompressedSize64 != 10 || !fh.Modified.Equal(fi.ModTime()) {
+		t.Fatalf("unexpected header %+v", fh)
+	}
+	if expected := cab.AttrReadOnly | cab.AttrExec; fh.Attributes != expected {
+		t.Fatalf("expected attributes %v, but got %v", expected, fh.Attributes)
+	}
+
+	fi, err = os.Stat(dir)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	fh, err = cab.FileInfoHeader(fi)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if fh.Name != filepath.Base(dir)+`\` || fh.Attributes != 0 || !fh.Mode().IsDir() {
+		t.Fatalf("unexpected header %+v", fh)
+	}
+}
@@ -0,0 +1,160 @@
+package cab
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// LayoutError reports files that do not tile their folder's decompressed stream, as
+// found by Reader.ValidateFileLayout. It matches ErrCorrupt.
+type LayoutError struct {
+	// Folder is the index of the folder in Reader.Folders.
+	Folder int
+	// Files names the files involved, in stream order.
+	Files []string
+	// Reason describes the problem.
+	Reason string
+}
+
+func (e *LayoutError) Error() string {
+	msg := fmt.Sprintf("%v: folder %d: %s", ErrCorrupt, e.Folder, e.Reason)
+	if len(e.Files) > 0 {
+		msg += fmt.Sprintf(" (%s)", strings.Join(quoteNames(e.Files), ", "))
+	}
+	return msg
+}
+
+// Is reports whether target is ErrCorrupt.
+func (e *LayoutError) Is(target error) bool {
+	return target == ErrCorrupt
+}
+
+func quoteNames(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return quoted
+}
+
+// ValidateFileLayout checks that the files of each folder cover its decompressed
+// stream contiguously, as a well-behaved producer lays them out: each file starts where
+// the one before it ends, the first at the start of the stream and the last ending at
+// its end, which is the sum of the sizes of the folder's blocks. Empty files, such as
+// directory markers, need only start within the stream. The first gap, overlap or file
+// reaching outside its stream is returned as a *LayoutError. A folder split across the
+// cabinets of a set has one stream, and its files' offsets count from the start of the
+// first part: once the parts are linked by NewSetReader or OpenSet, each is checked
+// against the stream of the whole chain, and only the last part checks the end.
+// Without the neighbouring parts, the start of a folder continued from the previous
+// cabinet and the end of one continued into the next are not checked. Only the block
+// headers are read; nothing is decompressed.
+func (c *Reader) ValidateFileLayout() error {
+	if err := c.checkFiles(); err != nil {
+		return err
+	}
+
+	for i, folder := range c.Folders {
+		if err := c.validateFolderLayout(i, folder); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateFolderLayout checks the files of folder, the one at idx in Folders, as
+// described by ValidateFileLayout.
+func (c *Reader) validateFolderLayout(idx int, folder *Folder) error {
+	index, err := c.blockIndex(folder)
+	if err != nil {
+		return err
+	}
+	var total int64
+	for _, e := range index {
+		total += int64(e.uncompressedSize)
+	}
+	fromPrev, toNext := folder.ContinuesFromPrev(), folder.ContinuesToNext()
+	base, streamSize, first, last, err := folderChain(folder, total)
+	if err != nil {
+		return err
+	}
+	bounded := !first.ContinuesFromPrev() && !last.ContinuesToNext()
+	// where this part's data ends is only known when every part before it is linked
+	checkEnd := !toNext && folder.next == nil && !first.ContinuesFromPrev()
+
+	layoutErr := func(reason string, files ...*File) error {
+		e := &LayoutError{Folder: idx, Reason: reason}
+		for _, file := range files {
+			e.Files = append(e.Files, file.DecodeName())
+		}
+		return e
+	}
+
+	sorted := make([]*File, 0, len(folder.Files))
+	for _, file := range folder.Files {
+		if file.uncompressedSize == 0 {
+			if bounded && int64(file.uncompressedOffset) > streamSize {
+				return layoutErr(fmt.Sprintf("empty file starts after the end of the %d byte stream", streamSize), file)
+			}
+			continue
+		}
+		sorted = append(sorted, file)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].uncompressedOffset < sorted[j].uncompressedOffset
+	})
+
+	var prev *File
+	var pos int64
+	for _, file := range sorted {
+		off, end := int64(file.uncompressedOffset), int64(file.uncompressedOffset)+file.Size()
+		switch {
+		case bounded && end > streamSize:
+			return layoutErr(fmt.Sprintf("file ends at %d, after the end of the %d byte stream", end, streamSize), file)
+		case prev == nil && off > 0 && !fromPrev:
+			return layoutErr(fmt.Sprintf("gap of %d bytes at the start of the stream", off), file)
+		case prev != nil && off > pos:
+			return layoutErr(fmt.Sprintf("gap of %d bytes between files", off-pos), prev, file)
+		case prev != nil && off < pos:
+			return layoutErr(fmt.Sprintf("files overlap by %d bytes", pos-off), prev, file)
+		}
+		prev, pos = file, end
+	}
+
+	if end := base + total; checkEnd && pos < end {
+		if prev == nil {
+			return layoutErr(fmt.Sprintf("%d bytes of stream are not part of any file", end-base))
+		}
+		return layoutErr(fmt.Sprintf("gap of %d bytes at the end of the stream", end-pos), prev)
+	}
+	return nil
+}
+
+// folderChain measures the stream folder is part of, given total, the size of folder's
+// own part. It returns the offset at which folder's part starts, the size of the parts
+// linked to it, and the first and last of them.
+func folderChain(folder *Folder, total int64) (base, size int64, first, last *Folder, err error) {
+	first = folder
+	for first.prev != nil {
+		first = first.prev
+	}
+	for p := first; p != nil; p = p.next {
+		n := total
+		if p != folder {
+			index, err := p.c.blockIndex(p)
+			if err != nil {
+				return 0, 0, nil, nil, err
+			}
+			n = 0
+			for _, e := range index {
+				n += int64(e.uncompressedSize)
+			}
+		} else {
+			base = size
+		}
+		size += n
+		last = p
+	}
+	return base, size, first, last, nil
+}
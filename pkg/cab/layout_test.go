@@ -0,0 +1,200 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderValidateFileLayout(t *testing.T) {
+	block := []rawBlock{{data: []byte("abcdef"), uncompressedSize: 6}}
+
+	testCases := []struct {
+		name          string
+		files         []rawFile
+		expectedFiles []string // nil when the layout is valid
+	}{
+		{
+			name: "contiguous",
+			files: []rawFile{
+				{name: "b.txt", size: 4, offset: 2},
+				{name: "a.txt", size: 2, offset: 0},
+				{name: `dir\`, offset: 6},
+			},
+		},
+		{
+			name:          "gap at start",
+			files:         []rawFile{{name: "a.txt", size: 5, offset: 1}},
+			expectedFiles: []string{"a.txt"},
+		},
+		{
+			name: "gap between",
+			files: []rawFile{
+				{name: "a.txt", size: 2, offset: 0},
+				{name: "b.txt", size: 3, offset: 3},
+			},
+			expectedFiles: []string{"a.txt", "b.txt"},
+		},
+		{
+			name: "overlap",
+			files: []rawFile{
+				{name: "a.txt", size: 4, offset: 0},
+				{name: "b.txt", size: 4, offset: 2},
+			},
+			expectedFiles: []string{"a.txt", "b.txt"},
+		},
+		{
+			name:          "gap at end",
+			files:         []rawFile{{name: "a.txt", size: 4, offset: 0}},
+			expectedFiles: []string{"a.txt"},
+		},
+		{
+			name:          "past end",
+			files:         []rawFile{{name: "a.txt", size: 7, offset: 0}},
+			expectedFiles: []string{"a.txt"},
+		},
+		{
+			name: "empty file past end",
+			files: []rawFile{
+				{name: "a.txt", size: 6, offset: 0},
+				{name: "b.txt", offset: 7},
+			},
+			expectedFiles: []string{"b.txt"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildRawCab(0, 0, tc.files, block)
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			err = r.ValidateFileLayout()
+			if tc.expectedFiles == nil {
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, cab.ErrCorrupt) {
+				t.Fatalf("expected ErrCorrupt, but got %v", err)
+			}
+			var le *cab.LayoutError
+			if !errors.As(err, &le) {
+				t.Fatalf("expected a LayoutError, but got %T", err)
+			}
+			if le.Folder != 0 {
+				t.Fatalf("expected folder 0, but got %d", le.Folder)
+			}
+			if !reflect.DeepEqual(le.Files, tc.expectedFiles) {
+				t.Fatalf("expected files %q, but got %q", tc.expectedFiles, le.Files)
+			}
+		})
+	}
+}
+
+func TestReaderValidateFileLayoutBuilt(t *testing.T) {
+	data := buildMSZIPCab(
+		[]testFile{{name: "a.txt", data: []byte("hello")}, {name: "b.txt", data: []byte("world")}},
+		[]testFile{{name: "c.txt", data: bytes.Repeat([]byte("c"), 100000)}},
+	)
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := r.ValidateFileLayout(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestReaderValidateFileLayoutSplitFolder(t *testing.T) {
+	a := []byte("a file that fits in the first volume")
+	split := bytes.Repeat([]byte("0123456789"), 300)
+	c := []byte("a file that fits in the second volume")
+	stream := append(append(append([]byte(nil), a...), split...), c...)
+	blocks := [][]byte{stream[:1000], stream[1000:2000], stream[2000:]}
+
+	build := func(cSize int) []*cab.Reader {
+		first := buildRawCab(0x2, 0,
+			[]rawFile{
+				{name: "a.txt", size: uint32(len(a))},
+				{name: "split.bin", size: uint32(len(split)), offset: uint32(len(a)), folder: 0xfffe},
+			},
+			[]rawBlock{
+				{data: blocks[0], uncompressedSize: uint16(len(blocks[0]))},
+				{data: blocks[1][:500]},
+			},
+		)
+		second := buildRawCab(0x1, 0,
+			[]rawFile{
+				{name: "split.bin", size: uint32(len(split)), offset: uint32(len(a)), folder: 0xfffd},
+				{name: "c.txt", size: uint32(cSize), offset: uint32(len(a) + len(split))},
+			},
+			[]rawBlock{
+				{data: blocks[1][500:], uncompressedSize: uint16(len(blocks[1]))},
+				{data: blocks[2], uncompressedSize: uint16(len(blocks[2]))},
+			},
+		)
+		binary.LittleEndian.PutUint16(second[34:], 1)
+
+		var volumes []*cab.Reader
+		for _, data := range [][]byte{first, second} {
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			volumes = append(volumes, r)
+		}
+		return volumes
+	}
+
+	validate := func(volumes []*cab.Reader) []error {
+		var errs []error
+		for _, v := range volumes {
+			errs = append(errs, v.ValidateFileLayout())
+		}
+		return errs
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		volumes := build(len(c))
+		for i, err := range validate(volumes) {
+			if err != nil {
+				t.Fatalf("expected no error for unlinked volume %d, but got %v", i, err)
+			}
+		}
+		if _, err := cab.NewSetReader(volumes...); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		for i, err := range validate(volumes) {
+			if err != nil {
+				t.Fatalf("expected no error for linked volume %d, but got %v", i, err)
+			}
+		}
+	})
+
+	t.Run("gap at end", func(t *testing.T) {
+		volumes := build(len(c) - 5)
+		if _, err := cab.NewSetReader(volumes...); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		errs := validate(volumes)
+		if errs[0] != nil {
+			t.Fatalf("expected no error for the first volume, but got %v", errs[0])
+		}
+		var le *cab.LayoutError
+		if !errors.As(errs[1], &le) {
+			t.Fatalf("expected a LayoutError, but got %v", errs[1])
+		}
+		if !reflect.DeepEqual(le.Files, []string{"c.txt"}) || le.Reason != "gap of 5 bytes at the end of the stream" {
+			t.Fatalf("unexpected error %v", le)
+		}
+	})
+}
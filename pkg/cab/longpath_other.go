@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package cab
+
+// longPath returns path unchanged; only Windows limits path lengths this way.
+func longPath(path string) string {
+	return path
+}
@@ -0,0 +1,32 @@
+//go:build windows
+// +build windows
+
+package cab
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the Windows MAX_PATH limit, including the terminating NUL.
+const maxPath = 260
+
+// longPath returns path in its extended-length form, prefixed with `\\?\`, when it is
+// too long for the Windows MAX_PATH limit. Extended-length paths must be absolute and
+// are not normalized by Windows, so the path is made absolute and cleaned first.
+func longPath(path string) string {
+	// directories are limited to MAX_PATH less room for an 8.3 file name
+	if len(path) < maxPath-12 || strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
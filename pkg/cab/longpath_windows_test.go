@@ -0,0 +1,29 @@
+//go:build windows
+// +build windows
+
+package cab
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLongPath(t *testing.T) {
+	long := `C:\` + strings.Repeat(`directory\`, 30) + "file.txt"
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: `C:\short\file.txt`, expected: `C:\short\file.txt`},
+		{path: long, expected: `\\?\` + long},
+		{path: `\\?\` + long, expected: `\\?\` + long},
+		{path: `\\server\share\` + long[3:], expected: `\\?\UNC\server\share\` + long[3:]},
+	}
+
+	for _, tc := range testCases {
+		if actual := longPath(tc.path); actual != tc.expected {
+			t.Fatalf("expected %q, but got %q", tc.expected, actual)
+		}
+	}
+}
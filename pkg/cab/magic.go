@@ -0,0 +1,15 @@
+package cab
+
+import "encoding/binary"
+
+// Magic is the signature that begins every cabinet, the first four bytes of its header.
+const Magic = "MSCF"
+
+// MagicUint32 is Magic read as a little-endian uint32, as the header stores it.
+const MagicUint32 uint32 = 0x4643534d
+
+// HasMagic reports whether b begins with the cabinet signature. It says nothing of
+// whether the rest of b is a valid cabinet.
+func HasMagic(b []byte) bool {
+	return len(b) >= len(Magic) && binary.LittleEndian.Uint32(b) == MagicUint32
+}
@@ -0,0 +1,33 @@
+package cab_test
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestHasMagic(t *testing.T) {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], cab.MagicUint32)
+	if string(b[:]) != cab.Magic {
+		t.Fatalf("expected MagicUint32 to encode %q, but got %q", cab.Magic, b)
+	}
+
+	testCases := []struct {
+		data     string
+		expected bool
+	}{
+		{data: "MSCF", expected: true},
+		{data: "MSCF\x00\x00\x00\x00", expected: true},
+		{data: "MSC"},
+		{data: ""},
+		{data: "mscf"},
+		{data: "PK\x03\x04"},
+	}
+	for _, tc := range testCases {
+		if actual := cab.HasMagic([]byte(tc.data)); actual != tc.expected {
+			t.Fatalf("expected %v for %q, but got %v", tc.expected, tc.data, actual)
+		}
+	}
+}
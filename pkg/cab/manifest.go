@@ -0,0 +1,52 @@
+package cab
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Manifest is a catalog of the files in a cabinet.
+type Manifest []ManifestEntry
+
+// ManifestEntry describes a single file in a Manifest.
+type ManifestEntry struct {
+	Path        string          `json:"path"`
+	Size        int64           `json:"size"`
+	ModTime     time.Time       `json:"modtime"`
+	Attributes  FileAttributes  `json:"attributes"`
+	Folder      int             `json:"folder"`
+	Compression CompressionType `json:"compression"`
+}
+
+// Manifest returns an entry for every file in the cabinet. Paths use forward slashes
+// and entries are in the order of FilesSorted, so the manifests of cabinets holding
+// the same files are identical regardless of their folder layout.
+func (c *Reader) Manifest() Manifest {
+	folderIdx := make(map[*Folder]int, len(c.Folders))
+	for i, folder := range c.Folders {
+		folderIdx[folder] = i
+	}
+
+	files := c.FilesSorted()
+	m := make(Manifest, 0, len(files))
+	for _, file := range files {
+		m = append(m, ManifestEntry{
+			Path:        normalizeName(file.DecodeName()),
+			Size:        file.Size(),
+			ModTime:     file.DateTime,
+			Attributes:  file.Attributes(),
+			Folder:      folderIdx[file.folder],
+			Compression: file.folder.compressionType,
+		})
+	}
+
+	return m
+}
+
+// WriteJSON writes the manifest to w as an indented JSON array.
+func (m Manifest) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
@@ -0,0 +1,77 @@
+package cab_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderManifest(t *testing.T) {
+	r, err := cab.OpenReader("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer r.Close()
+
+	m := r.Manifest()
+	if len(m) != 1 {
+		t.Fatalf("expected 1 entry, but got %d", len(m))
+	}
+
+	expected := cab.ManifestEntry{
+		Path:        "README.md",
+		Size:        38,
+		ModTime:     time.Date(2019, time.November, 21, 18, 44, 32, 0, time.UTC),
+		Attributes:  cab.AttrArchive,
+		Folder:      0,
+		Compression: cab.CompressionMSZIP,
+	}
+	if m[0] != expected {
+		t.Fatalf("expected %+v, but got %+v", expected, m[0])
+	}
+
+	var buf bytes.Buffer
+	if err := m.WriteJSON(&buf); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expectedJSON := `[
+  {
+    "path": "README.md",
+    "size": 38,
+    "modtime": "2019-11-21T18:44:32Z",
+    "attributes": 32,
+    "folder": 0,
+    "compression": 1
+  }
+]
+`
+	if buf.String() != expectedJSON {
+		t.Fatalf("expected %s, but got %s", expectedJSON, buf.String())
+	}
+}
+
+func TestReaderManifestFolders(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: `b\two.txt`, data: []byte("two")}},
+		[]testFile{{name: "a.txt", data: []byte("a")}},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	m := r.Manifest()
+	if len(m) != 2 {
+		t.Fatalf("expected 2 entries, but got %d", len(m))
+	}
+	if m[0].Path != "a.txt" || m[0].Folder != 1 {
+		t.Fatalf("expected a.txt in folder 1, but got %s in folder %d", m[0].Path, m[0].Folder)
+	}
+	if m[1].Path != "b/two.txt" || m[1].Folder != 0 {
+		t.Fatalf("expected b/two.txt in folder 0, but got %s in folder %d", m[1].Path, m[1].Folder)
+	}
+}
@@ -0,0 +1,48 @@
+package cab
+
+// MemoryEstimate describes how much data extracting a cabinet involves.
+type MemoryEstimate struct {
+	// LargestFolder is the uncompressed size of the largest folder: the memory needed
+	// to hold the whole of any one folder's decompressed data at once.
+	LargestFolder int64
+	// Total is the combined size of every file in the cabinet.
+	Total int64
+}
+
+// MemoryEstimate reports the memory needed to buffer the cabinet's folders and the
+// total size of its output, so callers on constrained systems can choose between
+// buffering a folder and streaming it. A folder's size is the sum of its blocks'
+// uncompressed sizes; if its block headers cannot be read, the end of its last file is
+// used instead. Nothing is decompressed.
+func (c *Reader) MemoryEstimate() MemoryEstimate {
+	est := MemoryEstimate{
+		Total: c.TotalUncompressedSize(),
+	}
+
+	for _, folder := range c.Folders {
+		if size := folder.uncompressedSize(); size > est.LargestFolder {
+			est.LargestFolder = size
+		}
+	}
+
+	return est
+}
+
+// uncompressedSize returns the size of the folder's decompressed data.
+func (f *Folder) uncompressedSize() int64 {
+	var size int64
+	index, err := f.c.blockIndex(f)
+	if err != nil {
+		for _, file := range f.Files {
+			if end := int64(file.uncompressedOffset) + file.Size(); end > size {
+				size = end
+			}
+		}
+		return size
+	}
+
+	for _, e := range index {
+		size += int64(e.uncompressedSize)
+	}
+	return size
+}
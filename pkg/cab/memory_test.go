@@ -0,0 +1,30 @@
+package cab_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderMemoryEstimate(t *testing.T) {
+	data := buildMSZIPCab(
+		[]testFile{
+			{name: "a.txt", data: bytes.Repeat([]byte("a"), 40000)},
+			{name: "b.txt", data: bytes.Repeat([]byte("b"), 30000)},
+		},
+		[]testFile{
+			{name: "c.txt", data: bytes.Repeat([]byte("c"), 50000)},
+		},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := cab.MemoryEstimate{LargestFolder: 70000, Total: 120000}
+	if actual := r.MemoryEstimate(); actual != expected {
+		t.Fatalf("expected %+v, but got %+v", expected, actual)
+	}
+}
@@ -0,0 +1,98 @@
+package cab
+
+import (
+	"fmt"
+	"io"
+	"path"
+)
+
+// Merge writes the files of each of srcs, in order, to dst as a single new cabinet,
+// compressed as specified by opts. Each source is copied as by Repack, so folders that
+// already use the target compression are not decompressed, unless it is one added by
+// RegisterCompressor, in which case they are compressed again.
+//
+// Names are compared as Windows compares them, ignoring case. A directory marker whose
+// name was used by an earlier source is left out, as the directory already exists.
+// Any other file whose name was used by an earlier source causes an error, unless
+// WithRenameDuplicates is given. Duplicate names within a single source are kept.
+func Merge(dst io.Writer, srcs []*Reader, opts ...WriterOption) error {
+	w := NewWriter(dst, opts...)
+	if w.err != nil {
+		return w.err
+	}
+
+	used := make(map[string]bool)
+	for _, src := range srcs {
+		if err := src.checkFiles(); err != nil {
+			return err
+		}
+
+		// names of this source, which a renamed file must not take either
+		own := make(map[string]bool, len(src.File))
+		for _, file := range src.File {
+			own[mergeKey(file.DecodeName())] = true
+		}
+		taken := func(key string) bool { return used[key] || own[key] }
+
+		names := make(map[*File]string)
+		for _, file := range src.File {
+			if !used[mergeKey(file.DecodeName())] {
+				continue
+			}
+			switch {
+			case file.IsDir():
+				names[file] = ""
+			case w.opts.renameDuplicates:
+				name := renameDuplicate(file.DecodeName(), taken)
+				own[mergeKey(name)] = true
+				names[file] = name
+			default:
+				return fmt.Errorf("cab: merging %q: a file of that name was already added", file.DecodeName())
+			}
+		}
+
+		for _, folder := range src.Folders {
+			var err error
+			if w.canCopy(folder) {
+				err = w.copyFolder(folder, names)
+			} else {
+				err = w.recompressFolder(folder, names)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, file := range src.File {
+			if name, ok := repackName(names, file); ok {
+				used[mergeKey(name)] = true
+			}
+		}
+	}
+
+	return w.Close()
+}
+
+// mergeKey returns the form of name used to find duplicates, which is the same for two
+// names exactly when equalNames reports them equal.
+func mergeKey(name string) string {
+	return foldName(name)
+}
+
+// renameDuplicate returns name with the first number from 2 that makes it not taken
+// added before its extension.
+func renameDuplicate(name string, taken func(key string) bool) string {
+	return renameDuplicateFrom(name, 2, taken)
+}
+
+// renameDuplicateFrom is like renameDuplicate, but tries numbers starting at from.
+func renameDuplicateFrom(name string, from int, taken func(key string) bool) string {
+	ext := path.Ext(normalizeName(name))
+	base := name[:len(name)-len(ext)]
+	for i := from; ; i++ {
+		renamed := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if !taken(mergeKey(renamed)) {
+			return renamed
+		}
+	}
+}
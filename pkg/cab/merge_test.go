@@ -0,0 +1,92 @@
+package cab_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestMerge(t *testing.T) {
+	first := buildMSZIPCab(
+		[]testFile{{name: "a.txt", data: []byte("first a")}, {name: `dir\`}},
+		[]testFile{{name: `dir\b.txt`, data: []byte("first b")}},
+	)
+	second := buildCab([]testFile{
+		{name: "c.txt", data: []byte("second c")},
+		{name: `dir\`},
+		{name: "A.TXT", data: []byte("second a")},
+		{name: "a (2).txt", data: []byte("second a 2")},
+	})
+
+	open := func(t *testing.T, data []byte) *cab.Reader {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		return r
+	}
+
+	t.Run("duplicate", func(t *testing.T) {
+		var buf bytes.Buffer
+		err := cab.Merge(&buf, []*cab.Reader{open(t, first), open(t, second)})
+		if err == nil || !strings.Contains(err.Error(), "A.TXT") {
+			t.Fatalf("expected an error naming A.TXT, but got %v", err)
+		}
+	})
+
+	t.Run("duplicate by case folding", func(t *testing.T) {
+		// U+017F, the long s, folds to s as FileByName compares names
+		var longS bytes.Buffer
+		if err := cab.WriteFiles(&longS, map[string][]byte{"\u017f.txt": []byte("long s")}); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		folded := open(t, longS.Bytes())
+		if _, ok := folded.FileByName("S.TXT"); !ok {
+			t.Fatalf("expected FileByName to match the folded name")
+		}
+
+		var buf bytes.Buffer
+		err := cab.Merge(&buf, []*cab.Reader{open(t, buildCab([]testFile{{name: "s.txt", data: []byte("s")}})), folded})
+		if err == nil || !strings.Contains(err.Error(), "already added") {
+			t.Fatalf("expected a duplicate name error, but got %v", err)
+		}
+	})
+
+	for _, compression := range []cab.CompressionType{cab.CompressionNone, cab.CompressionMSZIP} {
+		t.Run("rename "+compression.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			srcs := []*cab.Reader{open(t, first), open(t, second)}
+			if err := cab.Merge(&buf, srcs, cab.WithCompression(compression), cab.WithRenameDuplicates()); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			r := open(t, buf.Bytes())
+			if types := r.CompressionTypesUsed(); len(types) != 1 || types[0] != compression {
+				t.Fatalf("expected only %v to be used, but got %v", compression, types)
+			}
+
+			expected := map[string]string{
+				"a.txt":     "first a",
+				`dir\`:      "",
+				`dir\b.txt`: "first b",
+				"c.txt":     "second c",
+				"A (3).TXT": "second a",
+				"a (2).txt": "second a 2",
+			}
+			if len(r.File) != len(expected) {
+				t.Fatalf("expected %d files, but got %d", len(expected), len(r.File))
+			}
+			for _, file := range r.File {
+				content, ok := expected[file.Name]
+				if !ok {
+					t.Fatalf("unexpected file %q", file.Name)
+				}
+				if b := readFile(t, file); string(b) != content {
+					t.Fatalf("expected %q for %s, but got %q", content, file.Name, b)
+				}
+			}
+		})
+	}
+}
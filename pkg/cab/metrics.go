@@ -0,0 +1,90 @@
+package cab
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// ExtractMetrics describes the work done by a single extraction or verification, to
+// help profile where the time goes on large cabinets. It is reported to the function
+// given to WithMetrics.
+type ExtractMetrics struct {
+	// BytesRead is the number of bytes of block data read from the cabinet, not
+	// counting the blocks' headers and reserved areas.
+	BytesRead int64
+	// BytesDecompressed is the number of bytes the blocks read decompressed to.
+	BytesDecompressed int64
+	// Blocks is the number of data blocks read.
+	Blocks int64
+	// ChecksumTime is the time spent computing checksums.
+	ChecksumTime time.Duration
+	// DecompressTime is the time spent decompressing blocks. Folders are extracted
+	// concurrently, so it is the total across all of them and may exceed the time
+	// the extraction took.
+	DecompressTime time.Duration
+}
+
+// metricsCollector accumulates ExtractMetrics, safely for concurrent use. A nil
+// *metricsCollector collects nothing, so callers need not check whether metrics
+// were requested.
+type metricsCollector struct {
+	bytesRead         int64
+	bytesDecompressed int64
+	blocks            int64
+	checksumTime      int64
+	decompressTime    int64
+}
+
+// newMetricsCollector returns a collector if WithMetrics was specified, or nil.
+func (c *Reader) newMetricsCollector() *metricsCollector {
+	if c.opts.metrics == nil {
+		return nil
+	}
+	return &metricsCollector{}
+}
+
+// reportMetrics passes the collected metrics to the function given to WithMetrics.
+func (c *Reader) reportMetrics(m *metricsCollector) {
+	if m == nil {
+		return
+	}
+	c.opts.metrics(ExtractMetrics{
+		BytesRead:         atomic.LoadInt64(&m.bytesRead),
+		BytesDecompressed: atomic.LoadInt64(&m.bytesDecompressed),
+		Blocks:            atomic.LoadInt64(&m.blocks),
+		ChecksumTime:      time.Duration(atomic.LoadInt64(&m.checksumTime)),
+		DecompressTime:    time.Duration(atomic.LoadInt64(&m.decompressTime)),
+	})
+}
+
+func (m *metricsCollector) blockRead(n int) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.bytesRead, int64(n))
+	atomic.AddInt64(&m.blocks, 1)
+}
+
+// now returns the time at which a timed step starts, or the zero time if no metrics
+// are collected, so that the clock is only read when needed.
+func (m *metricsCollector) now() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	return time.Now()
+}
+
+func (m *metricsCollector) decompressed(n int, start time.Time) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.bytesDecompressed, int64(n))
+	atomic.AddInt64(&m.decompressTime, int64(time.Since(start)))
+}
+
+func (m *metricsCollector) checksummed(start time.Time) {
+	if m == nil {
+		return
+	}
+	atomic.AddInt64(&m.checksumTime, int64(time.Since(start)))
+}
@@ -0,0 +1,73 @@
+package cab_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestWithMetrics(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	data := buildMSZIPCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}, {name: "large.bin", data: large}},
+		[]testFile{{name: "b.txt", data: []byte("bbb")}},
+	)
+	setChecksums(t, data)
+
+	var reports []cab.ExtractMetrics
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithMetrics(func(m cab.ExtractMetrics) {
+		reports = append(reports, m)
+	}))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var blocks, compressed int64
+	for _, folder := range r.Folders {
+		infos, err := folder.Blocks()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		for _, info := range infos {
+			blocks++
+			compressed += int64(info.CompressedSize)
+		}
+	}
+
+	if err := r.VerifyChecksums(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, but got %d", len(reports))
+	}
+
+	verify, extract := reports[0], reports[1]
+	if verify.Blocks != blocks || verify.BytesRead != compressed || verify.BytesDecompressed != 0 {
+		t.Fatalf("expected %d blocks and %d bytes read verifying, but got %+v", blocks, compressed, verify)
+	}
+	if verify.DecompressTime != 0 {
+		t.Fatalf("expected no decompress time verifying, but got %+v", verify)
+	}
+
+	total := int64(1 + len(large) + 3)
+	if extract.Blocks != blocks || extract.BytesRead != compressed || extract.BytesDecompressed != total {
+		t.Fatalf("expected %d blocks, %d bytes read and %d decompressed extracting, but got %+v", blocks, compressed, total, extract)
+	}
+	if extract.ChecksumTime != 0 {
+		t.Fatalf("expected no checksum time extracting, but got %+v", extract)
+	}
+}
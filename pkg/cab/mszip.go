@@ -0,0 +1,87 @@
+package cab
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// mszipWindowSize is the size of the deflate history carried between MSZIP blocks.
+const mszipWindowSize = 32768
+
+// mszipDecompressor decodes MSZIP blocks. Each block is a "CK" signature followed by
+// a deflate stream that may refer back into the output of the previous blocks, so
+// the last 32KB of output is kept and used as the dictionary for the next block.
+type mszipDecompressor struct {
+	window []byte
+	fr     io.ReadCloser
+}
+
+func (d *mszipDecompressor) decompress(dst, src []byte, size int) ([]byte, error) {
+	if err := checkMSZIPSignature(src); err != nil {
+		return nil, err
+	}
+
+	br := bytes.NewReader(src[2:])
+	if d.fr == nil {
+		d.fr = flate.NewReader(br)
+	}
+	if err := d.fr.(flate.Resetter).Reset(br, d.window); err != nil {
+		return nil, err
+	}
+
+	dst, err := inflateBlock(d.fr, dst, size)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(dst) >= mszipWindowSize {
+		d.window = append(d.window[:0], dst[len(dst)-mszipWindowSize:]...)
+	} else {
+		d.window = append(d.window, dst...)
+		if len(d.window) > mszipWindowSize {
+			d.window = append(d.window[:0], d.window[len(d.window)-mszipWindowSize:]...)
+		}
+	}
+
+	return dst, nil
+}
+
+// mszipBlockDecompressor decodes a folder holding a single MSZIP block. Nothing
+// follows the block, so there is no history to carry and it is inflated on its own.
+type mszipBlockDecompressor struct{}
+
+func (mszipBlockDecompressor) decompress(dst, src []byte, size int) ([]byte, error) {
+	if err := checkMSZIPSignature(src); err != nil {
+		return nil, err
+	}
+
+	fr := flate.NewReader(bytes.NewReader(src[2:]))
+	defer fr.Close()
+
+	return inflateBlock(fr, dst, size)
+}
+
+func checkMSZIPSignature(src []byte) error {
+	if len(src) < 2 || src[0] != 'C' || src[1] != 'K' {
+		return newCorruptError("invalid MSZIP block signature")
+	}
+	return nil
+}
+
+// inflateBlock reads the size bytes of a decoded block from fr into dst.
+func inflateBlock(fr io.Reader, dst []byte, size int) ([]byte, error) {
+	if cap(dst) < size {
+		dst = make([]byte, size)
+	}
+	dst = dst[:size]
+
+	if _, err := io.ReadFull(fr, dst); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, newCorruptError("uncompressed block size mismatch")
+		}
+		return nil, &corruptError{err: err}
+	}
+
+	return dst, nil
+}
@@ -0,0 +1,109 @@
+package cab_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestMSZIP(t *testing.T) {
+	// random words so that matches reach back across block boundaries
+	rnd := rand.New(rand.NewSource(1))
+	words := []string{"cabinet ", "folder ", "file ", "block ", "deflate ", "window "}
+	var large []byte
+	for len(large) < 100000 {
+		large = append(large, words[rnd.Intn(len(words))]...)
+	}
+
+	testCases := []struct {
+		name    string
+		content []byte
+	}{
+		{name: "single block", content: []byte("a tiny single block MSZIP cabinet")},
+		{name: "full single block", content: large[:32768]},
+		{name: "multiple blocks", content: large},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildMSZIPCab([]testFile{{name: "file.txt", data: tc.content}})
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			b := readFile(t, r.Folders[0].Files[0])
+			if !bytes.Equal(b, tc.content) {
+				t.Fatalf("expected %d byte(s) of content, but got %d different byte(s)", len(tc.content), len(b))
+			}
+		})
+	}
+
+	// the single block path is the reference for the first block of the multiple block path
+	single := buildMSZIPCab([]testFile{{name: "file.txt", data: large[:32768]}})
+	multi := buildMSZIPCab([]testFile{{name: "file.txt", data: large}})
+
+	rs, err := cab.NewReader(bytes.NewReader(single), int64(len(single)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	rm, err := cab.NewReader(bytes.NewReader(multi), int64(len(multi)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := readFile(t, rs.Folders[0].Files[0])
+	actual, err := rm.Folders[0].Files[0].ReadRange(0, 32768)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(actual, expected) {
+		t.Fatalf("expected the first block of both paths to match")
+	}
+}
+
+func readFile(t *testing.T, file *cab.File) []byte {
+	t.Helper()
+
+	rc, err := file.Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	return b
+}
+
+func TestMSZIPReadRange(t *testing.T) {
+	content := make([]byte, 100000)
+	for i := range content {
+		content[i] = byte(i / 100)
+	}
+	data := buildMSZIPCab([]testFile{
+		{name: "pad.bin", data: bytes.Repeat([]byte{0xff}, 40000)},
+		{name: "file.bin", data: content},
+	})
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	file := r.Folders[0].Files[1]
+
+	for _, tc := range []struct{ off, length int64 }{{0, 10}, {25000, 40000}, {99990, 10}} {
+		b, err := file.ReadRange(tc.off, tc.length)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(b, content[tc.off:tc.off+tc.length]) {
+			t.Fatalf("unexpected content for range [%d, %d)", tc.off, tc.off+tc.length)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package cab
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// isSeparator reports whether c separates the components of a file name. The format
+// uses backslashes, but some cross-platform tools write forward slashes, so both are
+// accepted everywhere names are interpreted.
+func isSeparator(c byte) bool {
+	return c == '\\' || c == '/'
+}
+
+// splitName splits a file name into its components. Empty components are kept so
+// callers can reject names such as absolute paths.
+func splitName(name string) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(name); i++ {
+		if isSeparator(name[i]) {
+			parts = append(parts, name[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, name[start:])
+}
+
+// hasTrailingSeparator reports whether name ends with a separator.
+func hasTrailingSeparator(name string) bool {
+	return len(name) > 0 && isSeparator(name[len(name)-1])
+}
+
+// normalizeName converts a cabinet file name to a slash-separated path.
+func normalizeName(name string) string {
+	return strings.ReplaceAll(name, `\`, "/")
+}
+
+// equalNames reports whether two file names are the same as Windows compares them:
+// ignoring case, as strings.EqualFold does, with backslashes and forward slashes
+// interchangeable. Unlike comparing normalized copies, it does not allocate.
+func equalNames(s, t string) bool {
+	for s != "" && t != "" {
+		var sr, tr rune
+		if s[0] < utf8.RuneSelf {
+			sr, s = rune(s[0]), s[1:]
+		} else {
+			r, size := utf8.DecodeRuneInString(s)
+			sr, s = r, s[size:]
+		}
+		if t[0] < utf8.RuneSelf {
+			tr, t = rune(t[0]), t[1:]
+		} else {
+			r, size := utf8.DecodeRuneInString(t)
+			tr, t = r, t[size:]
+		}
+
+		if sr == '\\' {
+			sr = '/'
+		}
+		if tr == '\\' {
+			tr = '/'
+		}
+		if sr == tr {
+			continue
+		}
+
+		// make sr the smaller rune, then check whether tr is one of its case folds
+		if tr < sr {
+			sr, tr = tr, sr
+		}
+		if tr < utf8.RuneSelf {
+			if 'A' <= sr && sr <= 'Z' && tr == sr+'a'-'A' {
+				continue
+			}
+			return false
+		}
+
+		r := unicode.SimpleFold(sr)
+		for r != sr && r < tr {
+			r = unicode.SimpleFold(r)
+		}
+		if r != tr {
+			return false
+		}
+	}
+
+	return s == t
+}
+
+// foldName returns a form of name that is the same for exactly the names equalNames
+// considers equal, for use as a map key: each separator becomes a forward slash and
+// each rune the smallest of those it is equal to under simple case folding.
+func foldName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+	for _, r := range name {
+		switch {
+		case r == '\\':
+			r = '/'
+		case 'a' <= r && r <= 'z':
+			r -= 'a' - 'A'
+		case r >= utf8.RuneSelf:
+			min := r
+			for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+				if f < min {
+					min = f
+				}
+			}
+			r = min
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// replaceInvalidUTF8 returns s with each byte that is not part of a valid UTF-8
+// sequence, including those of overlong encodings and surrogates, replaced by U+FFFD, as
+// ranging over s would report them.
+func replaceInvalidUTF8(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(s) + 2)
+	for _, r := range s {
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// decodeName converts a file name as stored in a cabinet to UTF-8. Names with the
+// AttrNameIsUTF attribute are already UTF-8, although invalid sequences in them are
+// replaced as by replaceInvalidUTF8. Other names are in an unspecified code page; like
+// other extractors, non-ASCII bytes are interpreted as ISO-8859-1.
+func decodeName(raw string, attributes FileAttributes) string {
+	if attributes&AttrNameIsUTF != 0 {
+		return replaceInvalidUTF8(raw)
+	}
+	if isASCII(raw) {
+		return raw
+	}
+
+	// each byte is the code point of the same value, taking two bytes in UTF-8 if
+	// it is not ASCII
+	n := len(raw)
+	for i := 0; i < len(raw); i++ {
+		if raw[i] >= utf8.RuneSelf {
+			n++
+		}
+	}
+
+	var sb strings.Builder
+	sb.Grow(n)
+	for i := 0; i < len(raw); i++ {
+		sb.WriteRune(rune(raw[i]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,349 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestForwardSlashNames(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files []testFile
+	}{
+		{
+			name: "backslash",
+			files: []testFile{
+				{name: `dir\sub\a.txt`, data: []byte("a")},
+				{name: `dir\b.txt`, data: []byte("b")},
+				{name: `empty\`},
+			},
+		},
+		{
+			name: "forward slash",
+			files: []testFile{
+				{name: "dir/sub/a.txt", data: []byte("a")},
+				{name: "dir/b.txt", data: []byte("b")},
+				{name: "empty/"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildCab(tc.files)
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			for _, name := range []string{`dir\sub\a.txt`, "dir/sub/a.txt", `DIR\Sub/A.TXT`} {
+				file, ok := r.FileByName(name)
+				if !ok {
+					t.Fatalf("expected to find %q", name)
+				}
+				if file.Name != tc.files[0].name {
+					t.Fatalf("expected %q, but got %q", tc.files[0].name, file.Name)
+				}
+			}
+
+			if _, ok := r.FileByName("dir/missing.txt"); ok {
+				t.Fatalf("expected to not find dir/missing.txt")
+			}
+
+			empty, ok := r.FileByName("empty/")
+			if !ok || !empty.IsDir() {
+				t.Fatalf("expected empty/ to be a directory")
+			}
+
+			dir, err := ioutil.TempDir("", "cab")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			if err := r.ExtractTo(dir); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			expected := map[string]string{
+				filepath.Join("dir", "sub", "a.txt"): "a",
+				filepath.Join("dir", "b.txt"):        "b",
+			}
+			for name, content := range expected {
+				b, err := ioutil.ReadFile(filepath.Join(dir, name))
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if string(b) != content {
+					t.Fatalf("expected %q, but got %q", content, b)
+				}
+			}
+
+			fi, err := os.Stat(filepath.Join(dir, "empty"))
+			if err != nil || !fi.IsDir() {
+				t.Fatalf("expected empty to be a directory, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestLongNames(t *testing.T) {
+	const nameLen = 10 * 1024
+
+	testCases := []struct {
+		name     string
+		raw      string
+		utf      bool
+		expected string
+	}{
+		{
+			name:     "ascii",
+			raw:      strings.Repeat(`dir\`, nameLen/4-2) + "file.txt",
+			expected: strings.Repeat(`dir\`, nameLen/4-2) + "file.txt",
+		},
+		{
+			name:     "utf",
+			raw:      strings.Repeat("é", nameLen/2),
+			utf:      true,
+			expected: strings.Repeat("é", nameLen/2),
+		},
+		{
+			name:     "latin-1",
+			raw:      strings.Repeat("\xe9", nameLen),
+			expected: strings.Repeat("é", nameLen),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if len(tc.raw) != nameLen {
+				t.Fatalf("expected a %d byte name, but got %d bytes", nameLen, len(tc.raw))
+			}
+
+			data := buildCab([]testFile{
+				{name: tc.raw, data: []byte("long")},
+				{name: "short.txt", data: []byte("short")},
+			})
+			if tc.utf {
+				// attribs of the first file
+				binary.LittleEndian.PutUint16(data[58:], uint16(cab.AttrNameIsUTF))
+			}
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			files := r.Folders[0].Files
+			if len(files) != 2 {
+				t.Fatalf("expected 2 files, but got %d", len(files))
+			}
+			if files[0].Name != tc.expected {
+				t.Fatalf("expected a %d byte name, but got %d bytes", len(tc.expected), len(files[0].Name))
+			}
+			if files[1].Name != "short.txt" {
+				t.Fatalf("expected %q, but got %q", "short.txt", files[1].Name)
+			}
+
+			for i, expected := range []string{"long", "short"} {
+				b, err := files[i].ReadRange(0, files[i].Size())
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if string(b) != expected {
+					t.Fatalf("expected %q, but got %q", expected, b)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidUTF8Names(t *testing.T) {
+	files := []testFile{
+		{name: "valid é.txt", data: []byte("valid")},
+		{name: "overlong\xc0\xafslash.txt", data: []byte("overlong")},
+		{name: "truncated\xe2\x82.txt", data: []byte("truncated")},
+	}
+	data := buildCab(files)
+	// mark every name as UTF-8
+	off := 44
+	for _, f := range files {
+		binary.LittleEndian.PutUint16(data[off+14:], uint16(cab.AttrNameIsUTF))
+		off += 16 + len(f.name) + 1
+	}
+
+	t.Run("replace", func(t *testing.T) {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		expected := []string{"valid é.txt", "overlong\uFFFD\uFFFDslash.txt", "truncated\uFFFD\uFFFD.txt"}
+		for i, file := range r.File {
+			if file.Name != expected[i] {
+				t.Fatalf("expected %q, but got %q", expected[i], file.Name)
+			}
+		}
+
+		dir, err := ioutil.TempDir("", "cab")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := r.ExtractTo(dir); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, expected[1]))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != "overlong" {
+			t.Fatalf("expected %q, but got %q", "overlong", b)
+		}
+	})
+
+	t.Run("strict", func(t *testing.T) {
+		_, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithInvalidNamePolicy(cab.InvalidNameStrict))
+		if !errors.Is(err, cab.ErrCorrupt) {
+			t.Fatalf("expected ErrCorrupt, but got %v", err)
+		}
+		if !strings.Contains(err.Error(), `"overlong\xc0\xafslash.txt"`) {
+			t.Fatalf("expected the error to name the file, but got %v", err)
+		}
+
+		// names not marked as UTF-8 are never invalid
+		plain := buildCab(files)
+		r, err := cab.NewReader(bytes.NewReader(plain), int64(len(plain)), cab.WithInvalidNamePolicy(cab.InvalidNameStrict))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if r.File[1].Name != "overlongÀ¯slash.txt" {
+			t.Fatalf("expected the name to be read as ISO-8859-1, but got %q", r.File[1].Name)
+		}
+	})
+}
+
+func BenchmarkNewReaderNames(b *testing.B) {
+	testCases := []struct {
+		name   string
+		format string
+	}{
+		{name: "ASCII", format: `dir\file%05d.txt`},
+		{name: "ISO-8859-1", format: "dir\\caf\xe9%05d.txt"},
+	}
+
+	modes := []struct {
+		name string
+		opts []cab.ReaderOption
+	}{
+		{name: "eager"},
+		{name: "lazy", opts: []cab.ReaderOption{cab.WithLazyNames()}},
+	}
+
+	for _, tc := range testCases {
+		var files []testFile
+		for i := 0; i < 50000; i++ {
+			files = append(files, testFile{name: fmt.Sprintf(tc.format, i)})
+		}
+		data := buildCab(files)
+
+		for _, mode := range modes {
+			b.Run(tc.name+"/"+mode.name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), mode.opts...); err != nil {
+						b.Fatalf("expected no error, but got %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReaderLazyNames(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: "caf\xe9.txt", data: []byte("a")},
+		{name: `dir\`},
+	})
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithLazyNames())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	file := r.File[0]
+	if file.Name != "" {
+		t.Fatalf("expected the name to be left undecoded, but got %q", file.Name)
+	}
+	if name := file.DecodeName(); name != "caf\u00e9.txt" {
+		t.Fatalf("expected %q, but got %q", "caf\u00e9.txt", name)
+	}
+	if file.Name != "caf\u00e9.txt" {
+		t.Fatalf("expected Name to be filled in, but got %q", file.Name)
+	}
+
+	// methods needing a name decode it themselves
+	if !r.File[1].IsDir() {
+		t.Fatalf("expected a directory")
+	}
+	if f, ok := r.FileByName("caf\u00e9.txt"); !ok || f != file {
+		t.Fatalf("expected to find the file by its decoded name")
+	}
+}
+
+func BenchmarkReaderFileByName(b *testing.B) {
+	var files []testFile
+	for i := 0; i < 10000; i++ {
+		files = append(files, testFile{name: fmt.Sprintf(`dir\sub\file%05d.txt`, i)})
+	}
+	data := buildCab(files)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		b.Fatalf("expected no error, but got %v", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, ok := r.FileByName("DIR/SUB/FILE09999.TXT"); !ok {
+			b.Fatalf("expected to find the file")
+		}
+	}
+}
+
+func TestEqualNames(t *testing.T) {
+	testCases := []struct {
+		s, t     string
+		expected bool
+	}{
+		{s: `dir\file.txt`, t: "dir/file.txt", expected: true},
+		{s: `DIR\File.TXT`, t: "dir/file.txt", expected: true},
+		{s: "café.txt", t: "CAFÉ.TXT", expected: true},
+		{s: "kelvin", t: "Kelvin", expected: true},
+		{s: "file.txt", t: "file.txt2", expected: false},
+		{s: "file.txt", t: "file.tx", expected: false},
+		{s: `dir\file`, t: "dir_file", expected: false},
+		{s: "a", t: "b", expected: false},
+		{s: "", t: "", expected: true},
+	}
+
+	for _, tc := range testCases {
+		if actual := cab.EqualNames(tc.s, tc.t); actual != tc.expected {
+			t.Fatalf("expected %v comparing %q and %q, but got %v", tc.expected, tc.s, tc.t, actual)
+		}
+		if actual := cab.EqualNames(tc.t, tc.s); actual != tc.expected {
+			t.Fatalf("expected %v comparing %q and %q, but got %v", tc.expected, tc.t, tc.s, actual)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package cab
+
+import "io/fs"
+
+// DefaultMaxOpenFiles is the number of output files that may be open at once
+// during extraction when WithMaxOpenFiles is not specified.
+const DefaultMaxOpenFiles = 16
+
+// DefaultReadBufferSize is the size of the buffer used to read a cabinet's header and
+// file table when WithReadBufferSize is not specified.
+const DefaultReadBufferSize = 4096
+
+// DefaultDirMode is the permissions of the directories created during extraction when
+// WithDirMode is not specified.
+const DefaultDirMode fs.FileMode = 0755
+
+// ReaderOption configures optional behavior of a Reader.
+type ReaderOption func(*readerOptions)
+
+type readerOptions struct {
+	maxOpenFiles    int
+	lenientVersion  bool
+	lenientEmpty    bool
+	lenientReserve  bool
+	attributeMode   func(FileAttributes) fs.FileMode
+	restoreModTimes bool
+	verifyChecksums bool
+	allowSpecial    bool
+	metrics         func(ExtractMetrics)
+	headerOnly      bool
+	trimTrailing    bool
+	folderPrefix    bool
+	flatten         bool
+	skipUndecodable bool
+	dirMode         fs.FileMode
+	fileMode        fs.FileMode
+	setFileMode     bool
+	invalidNames    InvalidNamePolicy
+	readBufferSize  int
+	lazyNames       bool
+}
+
+func newReaderOptions(opts []ReaderOption) readerOptions {
+	o := readerOptions{
+		maxOpenFiles:   DefaultMaxOpenFiles,
+		attributeMode:  FileAttributes.Mode,
+		dirMode:        DefaultDirMode,
+		readBufferSize: DefaultReadBufferSize,
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.maxOpenFiles < 1 {
+		o.maxOpenFiles = 1
+	}
+	if o.attributeMode == nil {
+		o.attributeMode = FileAttributes.Mode
+	}
+
+	return o
+}
+
+// WithMaxOpenFiles bounds the number of output files that may be open
+// simultaneously while extracting. Values less than 1 are treated as 1.
+func WithMaxOpenFiles(n int) ReaderOption {
+	return func(o *readerOptions) {
+		o.maxOpenFiles = n
+	}
+}
+
+// WithReadBufferSize sets the size of the buffer through which a cabinet's header and
+// file table are read when it is opened. A larger buffer reads the file table in fewer,
+// larger reads, which helps when each read is costly, such as from a network file
+// system, and the cabinet holds many files. Values less than 16 are treated as 16.
+func WithReadBufferSize(n int) ReaderOption {
+	return func(o *readerOptions) {
+		o.readBufferSize = n
+	}
+}
+
+// WithLazyNames makes the Reader keep each file's name as stored until it is needed,
+// rather than decoding every name to UTF-8 while parsing. Name is then empty until
+// File.DecodeName is called, as the Reader's own methods do when they need a name. This
+// saves work when a cabinet has many files and only a few are used, such as by index
+// into File; looking files up by name, listing them or extracting them all decodes
+// every name anyway. By default names are decoded eagerly.
+func WithLazyNames() ReaderOption {
+	return func(o *readerOptions) {
+		o.lazyNames = true
+	}
+}
+
+// WithLenientVersion allows opening cabinets whose format version is newer than this
+// package understands. Rather than failing, the Reader records an
+// UnsupportedVersionError in its Warnings and parses the cabinet as the newest
+// supported version, which may be unreliable.
+func WithLenientVersion() ReaderOption {
+	return func(o *readerOptions) {
+		o.lenientVersion = true
+	}
+}
+
+// WithLenientEmptyFolders allows opening cabinets with a folder that has no data
+// blocks but contains files that are not empty. Rather than failing, the Reader records
+// the problem in its Warnings for each such file and treats the file as empty.
+func WithLenientEmptyFolders() ReaderOption {
+	return func(o *readerOptions) {
+		o.lenientEmpty = true
+	}
+}
+
+// WithLenientReserve tolerates cabinets whose reserve present flag does not match
+// their header, because it is clear although the header has reserve fields, or set
+// although it has none. Which layout the header has is judged by where the file table
+// begins; if that does not settle it, the flag is followed. A mismatch is recorded in
+// the Reader's Warnings.
+func WithLenientReserve() ReaderOption {
+	return func(o *readerOptions) {
+		o.lenientReserve = true
+	}
+}
+
+// WithAttributeMapper replaces the mapping from a file's attributes to its mode, which
+// is reported by File.Mode and used for the files written by ExtractTo. Only the
+// permission bits of the result are used. A nil mapper restores the default,
+// FileAttributes.Mode.
+func WithAttributeMapper(mapper func(FileAttributes) fs.FileMode) ReaderOption {
+	return func(o *readerOptions) {
+		o.attributeMode = mapper
+	}
+}
+
+// WithDirMode sets the permissions of the directories created by ExtractTo and
+// ExtractFile, both those named by directory markers and those implied by file names,
+// in place of DefaultDirMode. Only the permission bits of mode are used, and, as for
+// any directory created, the umask is applied to them. Directories that already exist
+// are left as they are.
+func WithDirMode(mode fs.FileMode) ReaderOption {
+	return func(o *readerOptions) {
+		o.dirMode = mode & fs.ModePerm
+	}
+}
+
+// WithFileMode sets the permissions of the files written by ExtractTo and ExtractFile,
+// in place of those derived from each file's attributes by File.Mode. Only the
+// permission bits of mode are used, and, as for any file created, the umask is applied
+// to them. File.Mode is unaffected.
+func WithFileMode(mode fs.FileMode) ReaderOption {
+	return func(o *readerOptions) {
+		o.fileMode = mode & fs.ModePerm
+		o.setFileMode = true
+	}
+}
+
+// InvalidNamePolicy is how a Reader handles file names that are marked as UTF-8, by
+// AttrNameIsUTF, but are not valid UTF-8.
+type InvalidNamePolicy int
+
+const (
+	// InvalidNameReplace replaces each byte that is not part of a valid UTF-8 sequence
+	// with U+FFFD, as ranging over a Go string does. It is the default.
+	InvalidNameReplace InvalidNamePolicy = iota
+	// InvalidNameStrict rejects the cabinet, with an error matching ErrCorrupt that
+	// names the file.
+	InvalidNameStrict
+)
+
+// WithInvalidNamePolicy sets how file names marked as UTF-8 that are not valid UTF-8,
+// such as those with overlong encodings or truncated sequences, are handled. By default
+// the invalid bytes are replaced, so that one bad name does not prevent reading the
+// rest of the cabinet.
+func WithInvalidNamePolicy(p InvalidNamePolicy) ReaderOption {
+	return func(o *readerOptions) {
+		o.invalidNames = p
+	}
+}
+
+// WithRestoreModTimes sets the modification time of each file written by ExtractTo to
+// the file's DateTime. Files without a time keep the time they were written.
+func WithRestoreModTimes() ReaderOption {
+	return func(o *readerOptions) {
+		o.restoreModTimes = true
+	}
+}
+
+// WithVerifyChecksums makes Unpack verify the checksums of every data block, using
+// Reader.VerifyChecksums, before extracting anything.
+func WithVerifyChecksums() ReaderOption {
+	return func(o *readerOptions) {
+		o.verifyChecksums = true
+	}
+}
+
+// WithAllowSpecialFiles disables the checks ExtractTo makes to refuse files that may
+// not be regular: files with attributes other than those the cabinet format defines,
+// such as AttrDirectory on a file that is not a directory marker, and names containing
+// a colon, which Windows interprets as an alternate data stream or a device.
+func WithAllowSpecialFiles() ReaderOption {
+	return func(o *readerOptions) {
+		o.allowSpecial = true
+	}
+}
+
+// WithTrimTrailingDotsAndSpaces makes ExtractTo remove the dots and spaces that end any
+// element of a file's name, as Windows does when creating files, so that a name such as
+// `dir.\file ` is written to dir/file on every platform. An element left empty is
+// rejected, as is a file that would then be written to the same path as another. By
+// default, names are kept as they are, which is possible everywhere but Windows; there,
+// such names are refused rather than silently changed.
+func WithTrimTrailingDotsAndSpaces() ReaderOption {
+	return func(o *readerOptions) {
+		o.trimTrailing = true
+	}
+}
+
+// WithFolderPrefixDirs makes ExtractTo write the files of each folder under their own
+// directory, named for the folder's index in Folders, such as folder0/dir/file.txt, to
+// show how the cabinet's author grouped files for compression. Files of the same name
+// in different folders then no longer overwrite each other.
+func WithFolderPrefixDirs() ReaderOption {
+	return func(o *readerOptions) {
+		o.folderPrefix = true
+	}
+}
+
+// WithFlatten makes ExtractTo write every file directly into the destination directory,
+// under only the last element of its name, discarding the directory structure; directory
+// markers create nothing. Where several files would share a name, ignoring case, each
+// after the first in stored order has a number added before its extension, such as
+// "readme (1).txt". It is meant for a quick look at a cabinet's files.
+func WithFlatten() ReaderOption {
+	return func(o *readerOptions) {
+		o.flatten = true
+	}
+}
+
+// WithSkipUndecodableFolders makes ExtractTo and ExtractToFunc carry on past a folder
+// whose data cannot be decoded at all, such as because the decompressor registered for
+// its compression type fails to initialize. Rather than failing, the Reader records the
+// FolderError in its Warnings and extracts the other folders; files of the folder
+// already written, such as empty ones, are left in place. Other errors still stop
+// extraction.
+func WithSkipUndecodableFolders() ReaderOption {
+	return func(o *readerOptions) {
+		o.skipUndecodable = true
+	}
+}
+
+// WithMetrics makes ExtractTo, ExtractToFunc and VerifyChecksums call fn when they
+// finish, whether or not they succeed, with a description of the work they did. Metrics
+// are only collected when this option is specified.
+func WithMetrics(fn func(m ExtractMetrics)) ReaderOption {
+	return func(o *readerOptions) {
+		o.metrics = fn
+	}
+}
+
+// WithHeaderOnly makes opening a cabinet parse only its header, including the
+// references to the previous and next cabinets of its set, and skip its folder and
+// file tables. This is much faster for cabinets with many files when only the version
+// or set membership is needed. Folders and File are left empty, methods that read
+// files, such as ExtractTo, Open and VerifyChecksums, return ErrHeaderOnly, and those
+// that only report on files, such as FileByName and Manifest, find none.
+func WithHeaderOnly() ReaderOption {
+	return func(o *readerOptions) {
+		o.headerOnly = true
+	}
+}
+
+// WriterOption configures optional behavior of a Writer.
+type WriterOption func(*writerOptions)
+
+type writerOptions struct {
+	compression      CompressionType
+	windowBits       int
+	blockSize        int
+	renameDuplicates bool
+}
+
+func newWriterOptions(opts []WriterOption) writerOptions {
+	o := writerOptions{
+		blockSize: maxBlockSize,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCompression sets the compression used for the folder written by a Writer. The
+// default is CompressionNone; CompressionMSZIP is also supported. Any other type
+// causes the Writer's methods to return an *UnsupportedCompressionError.
+func WithCompression(t CompressionType) WriterOption {
+	return func(o *writerOptions) {
+		o.compression = t
+	}
+}
+
+// WithWindowBits sets the window size, as a power of two, recorded for the folder of a
+// Writer and passed to the Compressor registered for its compression type, such as 15
+// to 21 for LZX. The built-in types have no window size, so it must be 0 for them, as
+// it is by default.
+func WithWindowBits(bits int) WriterOption {
+	return func(o *writerOptions) {
+		o.windowBits = bits
+	}
+}
+
+// WithBlockSize sets the number of uncompressed bytes a Writer puts in each data
+// block, which must be between 1 and 32768, the default. Smaller blocks let readers
+// reach data in an uncompressed folder with less wasted reading, at the cost of a
+// header for every block and, when compressed, a worse compression ratio. Any other
+// size causes the Writer's methods to return an error.
+func WithBlockSize(n int) WriterOption {
+	return func(o *writerOptions) {
+		o.blockSize = n
+	}
+}
+
+// WithRenameDuplicates makes Merge rename a file whose name is already used by a file of
+// an earlier source, by adding a number to it, such as "a (2).txt". By default, Merge
+// returns an error instead.
+func WithRenameDuplicates() WriterOption {
+	return func(o *writerOptions) {
+		o.renameDuplicates = true
+	}
+}
@@ -2,27 +2,37 @@ package cab
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 )
 
-// OpenReader will open the Cab file specified by name and return a ReadCloser.
-func OpenReader(name string) (*ReadCloser, error) {
+// OpenReader will open the Cab file specified by name and return a ReadCloser. Errors
+// opening the file are wrapped with its name.
+func OpenReader(name string, opts ...ReaderOption) (*ReadCloser, error) {
 	f, err := os.Open(name)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("cab: open %q: %w", name, err)
 	}
 
 	fi, err := f.Stat()
 	if err != nil {
 		f.Close()
-		return nil, err
+		return nil, fmt.Errorf("cab: open %q: %w", name, err)
 	}
 
 	var r ReadCloser
+	r.opts = newReaderOptions(opts)
 	if err := r.init(f, fi.Size()); err != nil {
 		f.Close()
 		return nil, err
@@ -44,13 +54,27 @@ func (rc *ReadCloser) Close() error {
 	return rc.f.Close()
 }
 
+// Reload parses the open file again, taking its current size, after it has been
+// rewritten in place. It is Reset with the same file, so the same rules apply:
+// nothing obtained from rc before Reload may be used after it, and it must not be
+// called while any other method of rc is in use. If the file was replaced by renaming
+// another over it, rc still reads the original; open the new file instead.
+func (rc *ReadCloser) Reload() error {
+	fi, err := rc.f.Stat()
+	if err != nil {
+		return err
+	}
+	return rc.Reset(rc.f, fi.Size())
+}
+
 // NewReader makes a Reader reading from r, which is assumed to ahve the give size in bytes.
-func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+func NewReader(r io.ReaderAt, size int64, opts ...ReaderOption) (*Reader, error) {
 	if size < 0 {
-		return nil, errors.New("zip: size cannot be negative")
+		return nil, errors.New("cab: size cannot be negative")
 	}
 
 	var c Reader
+	c.opts = newReaderOptions(opts)
 	if err := c.init(r, size); err != nil {
 		return nil, err
 	}
@@ -58,34 +82,155 @@ func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
 	return &c, nil
 }
 
+// NewReaderFromSeeker makes a Reader reading from rs, whose size is found by seeking to
+// its end. The cabinet must begin at offset 0 of rs.
+//
+// If rs does not also implement io.ReaderAt, each read seeks rs and then reads from
+// it, holding a lock so that concurrent reads, such as those made by ExtractTo, do not
+// interfere with each other. Files opened concurrently with File.Open are therefore
+// read one block at a time, in turn, rather than in parallel. In that case rs must not
+// be used by anything else while the Reader is in use, and its position afterwards is
+// unspecified.
+func NewReaderFromSeeker(rs io.ReadSeeker, opts ...ReaderOption) (*Reader, error) {
+	size, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	ra, ok := rs.(io.ReaderAt)
+	if !ok {
+		ra = &seekerReaderAt{rs: rs}
+	}
+
+	return NewReader(ra, size, opts...)
+}
+
+// seekerReaderAt adapts an io.ReadSeeker to an io.ReaderAt.
+type seekerReaderAt struct {
+	mu sync.Mutex
+	rs io.ReadSeeker
+}
+
+func (r *seekerReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.rs.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	n, err := io.ReadFull(r.rs, p)
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// Reset makes c read from r, which is assumed to have the given size in bytes,
+// discarding everything parsed from its previous source but keeping its options. The
+// capacity of its slices is reused, so pooled Readers can parse many cabinets without
+// reallocating. Folders, Files and readers returned by File.Open that came from the
+// previous source must not be used after Reset. If Reset returns an error, c must be
+// Reset again before it is used.
+//
+// Passing the same r re-parses it, which a long-lived Reader over a mutable source
+// must do after the source changes: everything is read by offset from r, but the
+// tables parsed from it are not read again otherwise. Reset must not be called while
+// any other method of c, or a reader obtained from it, is in use.
+//
+// Reset does not close the file of a ReadCloser; see ReadCloser.Reload.
+func (c *Reader) Reset(r io.ReaderAt, size int64) error {
+	if size < 0 {
+		return errors.New("cab: size cannot be negative")
+	}
+
+	files := c.File[:cap(c.File)]
+	for i := range files {
+		files[i] = nil
+	}
+	folders := c.Folders[:cap(c.Folders)]
+	for i := range folders {
+		folders[i] = nil
+	}
+	warnings := c.warnings[:cap(c.warnings)]
+	for i := range warnings {
+		warnings[i] = nil
+	}
+
+	*c = Reader{
+		File:     files[:0],
+		Folders:  folders[:0],
+		opts:     c.opts,
+		warnings: warnings[:0],
+	}
+	return c.init(r, size)
+}
+
 // Reader is a readable cab file.
 type Reader struct {
+	// File lists every file in the cabinet, across all its folders, in the order they
+	// are stored.
+	File    []*File
 	Folders []*Folder
 	PrevCab *Ref
 	NextCab *Ref
 
-	size         uint32
+	size         uint32 // the size declared in the header
+	fileSize     int64  // the size given when opening, which may exceed size
 	minorVersion uint8
 	majorVersion uint8
 	setID        uint16
 	setIdx       uint16
 
-	r io.ReaderAt
+	dataReserveSize uint8
+
+	headerEnd int64 // the end of the folder table
+	fileTable Region
+
+	r        io.ReaderAt
+	opts     readerOptions
+	warnings []error
+
+	skippedFolders map[int]bool // the folders recorded in warnings as skipped
+
+	fsOnce    sync.Once
+	fsEntries map[string]*fsEntry
+
+	flatOnce  sync.Once
+	flatNames map[*File]string
 }
 
 func (c *Reader) init(r io.ReaderAt, size int64) error {
-	c.r = r
+	c.fileSize = size
 	rs := io.NewSectionReader(r, 0, size)
-	buf := bufio.NewReader(rs)
+	buf := bufio.NewReaderSize(rs, c.opts.readBufferSize)
 	b := readBuf{buf: buf}
 
 	// signature
-	if b.uint32() != 0x4643534d { // "MSCF" stored little-endian
-		return errors.New("invalid file signature")
+	if b.uint32() != MagicUint32 {
+		if b.err != nil && b.err != io.EOF && b.err != io.ErrUnexpectedEOF {
+			return b.err
+		}
+		return ErrNotCabinet
 	}
 
 	b.skip(4)
 	c.size = b.uint32()
+	if b.err != nil {
+		return truncatedIfEOF(b.err)
+	}
+
+	// parse only within the declared size, ignoring anything appended after it
+	if int64(c.size) > size {
+		return &corruptError{err: ErrTruncated}
+	}
+	rs = io.NewSectionReader(r, 0, int64(c.size))
+	if _, err := rs.Seek(12, io.SeekStart); err != nil {
+		return err
+	}
+	buf.Reset(rs)
+	c.r = rs
+
 	b.skip(4)
 	firstFileOffset := b.uint32()
 	b.skip(4)
@@ -97,26 +242,46 @@ func (c *Reader) init(r io.ReaderAt, size int64) error {
 	c.setID = b.uint16()
 	c.setIdx = b.uint16()
 
+	if b.err != nil {
+		return truncatedIfEOF(b.err)
+	}
+	if err := c.checkVersion(); err != nil {
+		return err
+	}
+
 	// reserves
+	reserve := flags&flagReservePresent != 0
+	if c.opts.lenientReserve {
+		if inferred, ok := c.inferReserve(flags, numFolders, firstFileOffset); ok && inferred != reserve {
+			reason := "reserve present flag is set but the header has no reserve fields"
+			if inferred {
+				reason = "reserve present flag is clear but the header has reserve fields"
+			}
+			c.warnings = append(c.warnings, newCorruptError(reason))
+			reserve = inferred
+		}
+	}
+
 	var cabinetReserveSize uint16
 	var folderReserveSize uint8
 	var dataReserveSize uint8
-	if flags&0x4 != 0 {
+	if reserve {
 		cabinetReserveSize = b.uint16()
 		folderReserveSize = b.uint8()
 		dataReserveSize = b.uint8()
 	}
 
 	b.skip(int(cabinetReserveSize))
+	c.dataReserveSize = dataReserveSize
 
-	if flags&0x01 != 0 {
+	if flags&flagPrevCabinet != 0 {
 		c.PrevCab = &Ref{
 			Name: b.nullTerminatedString(),
 			Disk: b.nullTerminatedString(),
 		}
 	}
 
-	if flags&0x02 != 0 {
+	if flags&flagNextCabinet != 0 {
 		c.NextCab = &Ref{
 			Name: b.nullTerminatedString(),
 			Disk: b.nullTerminatedString(),
@@ -124,25 +289,51 @@ func (c *Reader) init(r io.ReaderAt, size int64) error {
 	}
 
 	if b.err != nil {
-		return b.err
+		return truncatedIfEOF(b.err)
+	}
+	if c.PrevCab != nil && c.PrevCab.Name == "" {
+		return newCorruptError("previous cabinet flag is set but its name is empty")
+	}
+	if c.NextCab != nil && c.NextCab.Name == "" {
+		return newCorruptError("next cabinet flag is set but its name is empty")
+	}
+	if c.opts.headerOnly {
+		c.headerEnd = b.off
+		return nil
 	}
 
-	c.Folders = make([]*Folder, 0, numFolders)
+	if cap(c.Folders) < int(numFolders) {
+		c.Folders = make([]*Folder, 0, numFolders)
+	}
 	for i := 0; i < int(numFolders); i++ {
-		c.Folders = append(c.Folders, &Folder{
+		folder := &Folder{
 			firstDataOffset: b.uint32(),
 			numDataBlocks:   b.uint16(),
-			compressionBits: b.uint16(),
-			compressionType: b.uint8(),
-		})
+			c:               c,
+			idx:             i,
+		}
+
+		typeCompress := b.uint16()
+		folder.compressionType = CompressionType(typeCompress & 0x000f)
+		folder.compressionBits = (typeCompress >> 8) & 0x001f
+
+		folder.Reserve = b.bytes(int(folderReserveSize))
+
+		c.Folders = append(c.Folders, folder)
+	}
 
-		b.skip(int(folderReserveSize))
+	if b.err != nil {
+		return truncatedIfEOF(b.err)
 	}
+	c.headerEnd = b.off
 
+	// only the folder table has a fixed position; the file table and each folder's
+	// data are found solely through their offsets, in whatever order they are laid out
 	if _, err := rs.Seek(int64(firstFileOffset), io.SeekStart); err != nil {
 		return err
 	}
 	buf.Reset(rs)
+	b.off = int64(firstFileOffset)
 
 	for i := 0; i < int(numFiles); i++ {
 		file := &File{
@@ -150,78 +341,687 @@ func (c *Reader) init(r io.ReaderAt, size int64) error {
 			uncompressedOffset: b.uint32(),
 		}
 
-		folderIdx := b.uint16()
-		if len(c.Folders) <= int(folderIdx) {
-			return errors.New("folder index out of range")
+		iFolder := b.uint16()
+		folderIdx := int(iFolder)
+		if isContinuation(iFolder, flags) {
+			file.continuation = uint16(folderIdx)
+			folderIdx = 0
+			if file.continuation == folderContinuedToNext {
+				folderIdx = len(c.Folders) - 1
+			}
+		}
+		if len(c.Folders) == 0 {
+			if b.err != nil {
+				return truncatedIfEOF(b.err)
+			}
+			return newCorruptError(fmt.Sprintf("file %d references folder %d but the cabinet has no folders", i, iFolder))
+		}
+		if folderIdx < 0 || len(c.Folders) <= folderIdx {
+			return newCorruptError("folder index out of range")
+		}
+		// a file spanning both neighbours is in a folder that is both the first and the
+		// last, so there can be no other
+		if file.continuation == folderContinuedPrevAndNext && len(c.Folders) != 1 {
+			return newCorruptError("file continued from the previous and into the next cabinet, but the cabinet has more than one folder")
 		}
 
-		_ = b.uint16() // date
-		_ = b.uint16() // time
+		date := b.uint16()
+		tm := b.uint16()
+		file.DateTime = msDosTimeToTime(date, tm)
 
 		file.attributes = b.uint16()
 
-		file.Name = b.nullTerminatedString() // need to handle UTF-8...
+		raw := b.nullTerminatedString()
+		if c.opts.invalidNames == InvalidNameStrict && file.Attributes()&AttrNameIsUTF != 0 && !utf8.ValidString(raw) {
+			return newCorruptError(fmt.Sprintf("name of file %d is not valid UTF-8: %q", i, raw))
+		}
+		if c.opts.lazyNames {
+			file.rawName = raw
+		} else {
+			file.Name = decodeName(raw, file.Attributes())
+		}
+
+		file.folder = c.Folders[folderIdx]
+		file.folder.Files = append(file.folder.Files, file)
+		c.File = append(c.File, file)
+	}
+	c.fileTable = Region{Offset: int64(firstFileOffset), Length: b.off - int64(firstFileOffset)}
+	if b.err != nil {
+		return truncatedIfEOF(b.err)
+	}
+
+	for i, folder := range c.Folders {
+		if err := c.checkEmptyFolder(i, folder); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inferReserve reports whether the header has reserve fields, judging by which layout
+// ends the folder table exactly where the file table begins, for when the reserve
+// present flag may be wrong. It reports false for ok if neither layout, or both, fit.
+func (c *Reader) inferReserve(flags, numFolders uint16, firstFileOffset uint32) (reserve, ok bool) {
+	var refs int
+	if flags&flagPrevCabinet != 0 {
+		refs += 2
+	}
+	if flags&flagNextCabinet != 0 {
+		refs += 2
+	}
+
+	// tableEnd returns the end of the folder table if the refs start at off and each
+	// folder entry has folderSize bytes
+	tableEnd := func(off int64, folderSize int) (int64, bool) {
+		for i := 0; i < refs; i++ {
+			n, ok := c.stringLen(off)
+			if !ok {
+				return 0, false
+			}
+			off += n
+		}
+		return off + int64(numFolders)*int64(folderSize), true
+	}
+
+	withoutEnd, withoutOK := tableEnd(36, 8)
+
+	var sizes [4]byte
+	withEnd, withOK := int64(0), readFullAt(c.r, sizes[:], 36) == nil
+	if withOK {
+		cabinetReserveSize := int64(binary.LittleEndian.Uint16(sizes[0:2]))
+		withEnd, withOK = tableEnd(40+cabinetReserveSize, 8+int(sizes[2]))
+	}
+
+	without := withoutOK && withoutEnd == int64(firstFileOffset)
+	with := withOK && withEnd == int64(firstFileOffset)
+	if without == with {
+		return false, false
+	}
+	return with, true
+}
+
+// stringLen returns the length, including its NUL, of the NUL-terminated string at off.
+func (c *Reader) stringLen(off int64) (int64, bool) {
+	var buf [256]byte
+	var n int64
+	for {
+		m, err := c.r.ReadAt(buf[:], off+n)
+		if i := bytes.IndexByte(buf[:m], 0); i >= 0 {
+			return n + int64(i) + 1, true
+		}
+		if err != nil {
+			return 0, false
+		}
+		n += int64(m)
+	}
+}
+
+// FilesSorted returns every file in the cabinet, across all folders, sorted by path.
+// Backslashes and forward slashes compare equally, so the order depends only on the
+// file names and not on how the files are arranged into folders. To visit files in
+// the order they are stored, iterate over Folders instead.
+func (c *Reader) FilesSorted() []*File {
+	var files []*File
+	for _, folder := range c.Folders {
+		files = append(files, folder.Files...)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		ni, nj := normalizeName(files[i].DecodeName()), normalizeName(files[j].DecodeName())
+		if ni != nj {
+			return ni < nj
+		}
+		return files[i].DecodeName() < files[j].DecodeName()
+	})
+
+	return files
+}
+
+// FilesByTopDir groups the files of the cabinet by the first element of their names,
+// with backslashes and forward slashes both separating elements, such as "dir" for
+// dir\sub\a.txt and for the directory marker dir\. Files with a single element, at the
+// root of the cabinet, are grouped under "". Within each group, files are in the order
+// of File.
+func (c *Reader) FilesByTopDir() map[string][]*File {
+	groups := make(map[string][]*File)
+	for _, file := range c.File {
+		name := strings.TrimLeft(normalizeName(file.DecodeName()), "/")
+		var top string
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			top = name[:i]
+		}
+		groups[top] = append(groups[top], file)
+	}
+	return groups
+}
+
+// FileByName returns the file with the given name. Names are compared as Windows
+// does, ignoring case, and backslashes and forward slashes are interchangeable.
+func (c *Reader) FileByName(name string) (*File, bool) {
+	for _, folder := range c.Folders {
+		for _, file := range folder.Files {
+			if equalNames(file.DecodeName(), name) {
+				return file, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// OpenInFolder opens the file at index fileIdx of the Files of the folder at index
+// folderIdx of Folders, as File.Open does. It is the counterpart of FileByName for
+// callers walking Folders themselves.
+func (c *Reader) OpenInFolder(folderIdx, fileIdx int) (io.ReadCloser, error) {
+	if folderIdx < 0 || folderIdx >= len(c.Folders) {
+		return nil, fmt.Errorf("cab: folder index %d out of range [0, %d)", folderIdx, len(c.Folders))
+	}
+	files := c.Folders[folderIdx].Files
+	if fileIdx < 0 || fileIdx >= len(files) {
+		return nil, fmt.Errorf("cab: file index %d out of range [0, %d) in folder %d", fileIdx, len(files), folderIdx)
+	}
+
+	return files[fileIdx].Open()
+}
+
+// FolderOf returns the index in Folders of the folder holding f, and the folder itself.
+// It returns false if f is not a file of c.
+func (c *Reader) FolderOf(f *File) (int, *Folder, bool) {
+	if f == nil || f.folder == nil || f.folder.c != c {
+		return 0, nil, false
+	}
+	return f.folder.idx, f.folder, true
+}
+
+// The flags of a cabinet's header.
+const (
+	flagPrevCabinet    = 0x0001 // the cabinet continues a previous one in its set
+	flagNextCabinet    = 0x0002 // the cabinet is continued by a next one in its set
+	flagReservePresent = 0x0004 // the header holds the sizes of the reserved areas
+)
+
+// The values of a file's folder index marking a file that spans cabinets in a set.
+const (
+	folderContinuedFromPrev    = 0xfffd // in the first folder, begun in the previous cabinet
+	folderContinuedToNext      = 0xfffe // in the last folder, finished in the next cabinet
+	folderContinuedPrevAndNext = 0xffff // in the first folder, spanning both neighbours
+)
+
+// isContinuation reports whether idx is one of the continuation values of a folder
+// index, given the cabinet's header flags. The values are only reserved in a cabinet
+// that has the neighbours they refer to; otherwise they are ordinary folder indexes.
+func isContinuation(idx, flags uint16) bool {
+	switch idx {
+	case folderContinuedFromPrev:
+		return flags&flagPrevCabinet != 0
+	case folderContinuedToNext:
+		return flags&flagNextCabinet != 0
+	case folderContinuedPrevAndNext:
+		return flags&(flagPrevCabinet|flagNextCabinet) == flagPrevCabinet|flagNextCabinet
+	}
+	return false
+}
+
+// CabinetSize returns the size of the cabinet declared in its header. Anything after it
+// in the underlying data, such as padding or a signature appended by another tool, is
+// ignored; see UnreferencedRegions.
+func (c *Reader) CabinetSize() int64 {
+	return int64(c.size)
+}
+
+// SetID returns the identifier shared by the cabinets of a set, as recorded in the
+// cabinet's header.
+func (c *Reader) SetID() uint16 {
+	return c.setID
+}
+
+// SetIndex returns the position of the cabinet in its set, starting at 0.
+func (c *Reader) SetIndex() int {
+	return int(c.setIdx)
+}
+
+// checkFiles returns ErrHeaderOnly if the cabinet's files were not read because it
+// was opened with WithHeaderOnly.
+func (c *Reader) checkFiles() error {
+	if c.opts.headerOnly {
+		return ErrHeaderOnly
+	}
+	return nil
+}
+
+// TotalUncompressedSize returns the sum of the sizes of the files in the cabinet,
+// which is the disk space needed to extract it. A file spanning cabinets is listed
+// in each of them with its full size, so it is counted fully here too; use
+// SetReader.TotalUncompressedSize to count it once across a set.
+func (c *Reader) TotalUncompressedSize() int64 {
+	var total int64
+	for _, folder := range c.Folders {
+		for _, file := range folder.Files {
+			total += file.Size()
+		}
+	}
+	return total
+}
+
+// NumFiles returns the number of files read from the cabinet's file table, which is
+// len(File). It is 0 for a cabinet opened with WithHeaderOnly.
+func (c *Reader) NumFiles() int {
+	return len(c.File)
+}
+
+// NumFolders returns the number of folders read from the cabinet's folder table, which
+// is len(Folders). It is 0 for a cabinet opened with WithHeaderOnly.
+func (c *Reader) NumFolders() int {
+	return len(c.Folders)
+}
+
+// Summary describes the contents of a cabinet.
+type Summary struct {
+	// Files is the number of files, as returned by NumFiles.
+	Files int
+	// Folders is the number of folders, as returned by NumFolders.
+	Folders int
+	// UncompressedSize is the sum of the sizes of the files, as returned by
+	// TotalUncompressedSize.
+	UncompressedSize int64
+}
 
-		c.Folders[folderIdx].Files = append(c.Folders[folderIdx].Files, file)
-		b.skip(int(dataReserveSize))
+// Summary returns the number of files and folders in the cabinet and their total size.
+// The counts are those actually read, so they agree with File and Folders even when
+// the header declares others.
+func (c *Reader) Summary() Summary {
+	return Summary{
+		Files:            c.NumFiles(),
+		Folders:          c.NumFolders(),
+		UncompressedSize: c.TotalUncompressedSize(),
 	}
+}
 
-	return b.err
+// IsForwardReadable reports whether every file can be extracted in a single forward
+// pass over the cabinet, without seeking backward, so that its source need not be
+// seekable. That is so when the file table precedes the folders' data, files are
+// stored folder by folder in the order of the folders' data, and each folder's files
+// follow one another through its stream without overlapping. It is judged from the
+// folder data offsets and the file table alone; nothing is decompressed.
+func (c *Reader) IsForwardReadable() bool {
+	end := c.fileTable.Offset + c.fileTable.Length
+	var folder *Folder
+	var pos int64
+	seen := make(map[*Folder]bool, len(c.Folders))
+	for _, file := range c.File {
+		if file.folder != folder {
+			folder = file.folder
+			if seen[folder] || int64(folder.firstDataOffset) < end {
+				return false
+			}
+			seen[folder] = true
+			end = int64(folder.firstDataOffset)
+			pos = 0
+		}
+		if int64(file.uncompressedOffset) < pos {
+			return false
+		}
+		pos = int64(file.uncompressedOffset) + file.Size()
+	}
+	return true
 }
 
-// Ref is a reference to another cabinet.
+// Ref is a reference to another cabinet of a set.
 type Ref struct {
+	// Disk is the name of the disk holding the cabinet, which may be empty.
 	Disk string
+	// Name is the file name of the cabinet.
 	Name string
 }
 
+// String returns the cabinet's name, preceded by its disk's name if it has one, as in
+// "disk2: data2.cab".
+func (r *Ref) String() string {
+	if r.Disk == "" {
+		return r.Name
+	}
+	return r.Disk + ": " + r.Name
+}
+
 // Folder is metadata about a folder in a cabinet.
 type Folder struct {
 	Files []*File
+	// Reserve is the folder entry's reserved area, which some cabinets use for their
+	// own per-folder data. It is nil if the cabinet reserves none.
+	Reserve []byte
 
 	firstDataOffset uint32
 	numDataBlocks   uint16
 	compressionBits uint16
-	compressionType uint8
+	compressionType CompressionType
+
+	c   *Reader
+	idx int // the position of the folder in c.Folders
+
+	// the parts of a folder continued across the cabinets of a set; see NewSetReader
+	prev *Folder
+	next *Folder
+
+	indexOnce sync.Once
+	index     []blockEntry
+	indexErr  error
+}
+
+// ContinuesFromPrev reports whether the folder's data begins in the previous cabinet of
+// its set. Only the first folder of a cabinet can do so, and only when it holds a file
+// continued from the previous cabinet.
+func (f *Folder) ContinuesFromPrev() bool {
+	if f.c.PrevCab == nil || f != f.c.Folders[0] {
+		return false
+	}
+	for _, file := range f.Files {
+		if file.continuation == folderContinuedFromPrev || file.continuation == folderContinuedPrevAndNext {
+			return true
+		}
+	}
+	return false
+}
+
+// ContinuesToNext reports whether the folder's data continues in the next cabinet of
+// its set. Only the last folder of a cabinet can do so, and only when it holds a file
+// continued in the next cabinet.
+func (f *Folder) ContinuesToNext() bool {
+	if f.c.NextCab == nil || f != f.c.Folders[len(f.c.Folders)-1] {
+		return false
+	}
+	for _, file := range f.Files {
+		if file.continuation == folderContinuedToNext || file.continuation == folderContinuedPrevAndNext {
+			return true
+		}
+	}
+	return false
+}
+
+// head returns the first part of a folder continued across cabinets.
+func (f *Folder) head() *Folder {
+	for f.prev != nil {
+		f = f.prev
+	}
+	return f
 }
 
 // File is metadata about a file in a cabinet.
 type File struct {
+	// Name is the file's name, decoded to UTF-8. With WithLazyNames it is empty until
+	// DecodeName is called.
 	Name     string
 	DateTime time.Time
 
+	rawName  string // the name as stored, until decoded with WithLazyNames
+	nameOnce sync.Once
+
 	uncompressedSize   uint32
 	uncompressedOffset uint32
 	attributes         uint16
+	continuation       uint16 // the continuation value of the folder index, or 0
+
+	folder *Folder
+
+	mu          sync.Mutex
+	contentType string
+}
+
+// DecodeName returns the file's name, as Name holds it. With WithLazyNames, the name is
+// decoded the first time DecodeName is called and then stored in Name; it is safe to
+// call concurrently. Methods that need the name, such as IsDir, decode it themselves.
+func (f *File) DecodeName() string {
+	f.nameOnce.Do(func() {
+		if f.rawName != "" {
+			f.Name = decodeName(f.rawName, f.Attributes())
+			f.rawName = ""
+		}
+	})
+	return f.Name
+}
+
+// Attributes returns the file's attribute flags.
+func (f *File) Attributes() FileAttributes {
+	return FileAttributes(f.attributes)
+}
+
+// Mode returns the file's permission bits, derived from its attributes by the
+// Reader's attribute mapper, with fs.ModeDir set if the file is a directory marker.
+func (f *File) Mode() fs.FileMode {
+	mode := f.folder.c.opts.attributeMode(f.Attributes()).Perm()
+	if f.IsDir() {
+		mode |= fs.ModeDir
+	}
+	return mode
+}
+
+// Size returns the uncompressed size of the file.
+func (f *File) Size() int64 {
+	return int64(f.uncompressedSize)
+}
+
+// IsDir reports whether the file is a directory marker: a zero-length file whose name
+// ends in a separator. See Writer.AddDir.
+func (f *File) IsDir() bool {
+	return f.uncompressedSize == 0 && hasTrailingSeparator(f.DecodeName())
+}
+
+// Open returns a ReadCloser that provides access to the File's contents. The folder
+// holding the file is decompressed from its start up to the end of the file.
+func (f *File) Open() (io.ReadCloser, error) {
+	if f.uncompressedSize == 0 {
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+
+	fr, err := f.folder.c.openFolder(f.folder)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, fr, int64(f.uncompressedOffset)); err != nil {
+		return nil, truncatedIfEOF(err)
+	}
+
+	return ioutil.NopCloser(&fileReader{r: fr, remaining: int64(f.uncompressedSize)}), nil
+}
+
+// ReadRange returns length bytes of the file's contents starting at off, which must
+// lie within Size.
+//
+// For an uncompressed folder, the bytes are read directly from the data blocks that
+// hold them. A compressed folder cannot be entered part way through, since each block
+// may refer back to the data before it, so it is decoded from its start up to the end
+// of the range. The cost of reading a range from a compressed folder therefore grows
+// with the range's position in the folder, not just its length.
+func (f *File) ReadRange(off, length int64) ([]byte, error) {
+	if off < 0 || length < 0 || off > f.Size() || length > f.Size()-off {
+		return nil, errors.New("cab: invalid range")
+	}
+	if length == 0 {
+		return []byte{}, nil
+	}
+
+	if f.folder.compressionType == CompressionNone && f.folder.prev == nil && f.folder.next == nil {
+		b := make([]byte, length)
+		if err := f.folder.c.readStoredAt(f.folder, b, int64(f.uncompressedOffset)+off); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+
+	fr, err := f.folder.c.openFolder(f.folder)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, fr, int64(f.uncompressedOffset)+off); err != nil {
+		return nil, truncatedIfEOF(err)
+	}
+
+	b := make([]byte, length)
+	if _, err := io.ReadFull(fr, b); err != nil {
+		return nil, truncatedIfEOF(err)
+	}
+
+	return b, nil
+}
+
+// Peek returns the first n bytes of the file's contents, or all of them if the file is
+// smaller, such as to sniff its type from a magic number. Only the blocks up to the one
+// holding the last byte returned are decoded, as by ReadRange.
+func (f *File) Peek(n int) ([]byte, error) {
+	if n < 0 {
+		return nil, errors.New("cab: negative count")
+	}
+	length := int64(n)
+	if size := f.Size(); length > size {
+		length = size
+	}
+	return f.ReadRange(0, length)
+}
+
+// continuedFromPrev reports whether the file begins in the previous cabinet of its
+// set, in which case that cabinet lists it too.
+func (f *File) continuedFromPrev() bool {
+	return f.continuation == folderContinuedFromPrev || f.continuation == folderContinuedPrevAndNext
+}
+
+// fileReader reads exactly remaining bytes of a file from a folder stream.
+type fileReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (r *fileReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.r.Read(p)
+	r.remaining -= int64(n)
+	if err == io.EOF && r.remaining > 0 {
+		err = truncatedIfEOF(err)
+	}
+	return n, err
+}
+
+// msDosTimeToTime converts an MS-DOS date and time into a time.Time. The cabinet
+// format does not record a time zone, so the result is in UTC. A zero date and time
+// converts to the zero time.Time.
+func msDosTimeToTime(dosDate, dosTime uint16) time.Time {
+	if dosDate == 0 && dosTime == 0 {
+		return time.Time{}
+	}
+
+	return time.Date(
+		// date bits 0-4: day of month; 5-8: month; 9-15: years since 1980
+		int(dosDate>>9+1980),
+		time.Month(dosDate>>5&0xf),
+		int(dosDate&0x1f),
+
+		// time bits 0-4: second/2; 5-10: minute; 11-15: hour
+		int(dosTime>>11),
+		int(dosTime>>5&0x3f),
+		int(dosTime&0x1f*2),
+		0, // nanoseconds
+
+		time.UTC,
+	)
+}
+
+// timeToMsDosTime converts t into an MS-DOS date and time, the inverse of
+// msDosTimeToTime. Times that MS-DOS cannot represent, including the zero time.Time,
+// convert to a zero date and time.
+func timeToMsDosTime(t time.Time) (dosDate, dosTime uint16) {
+	if t.Year() < 1980 || t.Year() > 2107 {
+		return 0, 0
+	}
+
+	dosDate = uint16(t.Year()-1980)<<9 | uint16(t.Month())<<5 | uint16(t.Day())
+	dosTime = uint16(t.Hour())<<11 | uint16(t.Minute())<<5 | uint16(t.Second()/2)
+	return dosDate, dosTime
 }
 
 type readBuf struct {
 	buf  *bufio.Reader
 	temp [4]byte
+	off  int64 // the offset in the cabinet of the next byte read
 	err  error
 }
 
-func (b *readBuf) nullTerminatedString() (s string) {
-	s, b.err = b.buf.ReadString(0x0)
+// nullTerminatedString reads a NUL-terminated string, without the NUL. If the data
+// ends before a NUL, the partial string is discarded and the error is recorded.
+func (b *readBuf) nullTerminatedString() string {
+	if b.err != nil {
+		return ""
+	}
+	s, err := b.buf.ReadString(0x0)
+	b.off += int64(len(s))
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		b.err = err
+		return ""
+	}
 	return s[:len(s)-1]
 }
 
+// bytes reads n bytes into a new slice, or returns nil if n is 0.
+func (b *readBuf) bytes(n int) []byte {
+	if b.err != nil || n == 0 {
+		return nil
+	}
+	p := make([]byte, n)
+	var m int
+	m, b.err = io.ReadFull(b.buf, p)
+	b.off += int64(m)
+	if b.err != nil {
+		return nil
+	}
+	return p
+}
+
 func (b *readBuf) skip(n int) {
-	_, b.err = b.buf.Discard(n)
+	if b.err != nil {
+		return
+	}
+	var m int
+	m, b.err = b.buf.Discard(n)
+	b.off += int64(m)
 }
 
 func (b *readBuf) uint8() uint8 {
+	if b.err != nil {
+		return 0
+	}
 	r, err := b.buf.ReadByte()
 	b.err = err
+	if err == nil {
+		b.off++
+	}
 	return r
 }
 
 func (b *readBuf) uint16() uint16 {
-	_, b.err = b.buf.Read(b.temp[:2])
+	if b.err != nil {
+		return 0
+	}
+	var n int
+	n, b.err = io.ReadFull(b.buf, b.temp[:2])
+	b.off += int64(n)
 	return binary.LittleEndian.Uint16(b.temp[:2])
 }
 
 func (b *readBuf) uint32() uint32 {
-	_, b.err = b.buf.Read(b.temp[:])
+	if b.err != nil {
+		return 0
+	}
+	var n int
+	n, b.err = io.ReadFull(b.buf, b.temp[:])
+	b.off += int64(n)
 	return binary.LittleEndian.Uint32(b.temp[:])
 }
@@ -1,6 +1,17 @@
 package cab_test
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/craiggwilson/go-cab/pkg/cab"
@@ -51,3 +62,1237 @@ func stringSliceContains(slice []string, s string) bool {
 
 	return false
 }
+
+func TestOpenReaderNotExist(t *testing.T) {
+	path := filepath.Join("testdata", "missing.cab")
+	_, err := cab.OpenReader(path)
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("expected fs.ErrNotExist, but got %v", err)
+	}
+	if !strings.Contains(err.Error(), fmt.Sprintf("%q", path)) {
+		t.Fatalf("expected the error to name %q, but got %v", path, err)
+	}
+}
+
+func TestFileOpen(t *testing.T) {
+	files := []testFile{
+		{name: "first.txt"},
+		{name: "data.txt", data: []byte("data")},
+		{name: "middle.txt"},
+		{name: "more.txt", data: []byte("more")},
+		{name: "last.txt"},
+	}
+	data := buildCab(files)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	for i, file := range r.Folders[0].Files {
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(b, files[i].data) {
+			t.Fatalf("expected %q for %s, but got %q", files[i].data, file.Name, b)
+		}
+	}
+}
+
+func TestFileReadRange(t *testing.T) {
+	big := make([]byte, 100000)
+	for i := range big {
+		big[i] = byte(i * 7)
+	}
+	data := buildCab([]testFile{
+		{name: "pad.bin", data: bytes.Repeat([]byte{0xff}, 40000)},
+		{name: "big.bin", data: big},
+	})
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	file := r.Folders[0].Files[1]
+
+	testCases := []struct {
+		off, length int64
+	}{
+		{off: 0, length: 10},
+		{off: 25000, length: 100},
+		{off: 25000, length: 40000},
+		{off: 99990, length: 10},
+		{off: 100000, length: 0},
+	}
+
+	for _, tc := range testCases {
+		b, err := file.ReadRange(tc.off, tc.length)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(b, big[tc.off:tc.off+tc.length]) {
+			t.Fatalf("unexpected content for range [%d, %d)", tc.off, tc.off+tc.length)
+		}
+	}
+
+	for _, tc := range []struct{ off, length int64 }{{-1, 1}, {0, -1}, {0, 100001}, {100000, 1}} {
+		if _, err := file.ReadRange(tc.off, tc.length); err == nil {
+			t.Fatalf("expected an error for range (%d, %d), but got none", tc.off, tc.length)
+		}
+	}
+
+	rc, err := cab.OpenReader("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer rc.Close()
+
+	b, err := rc.Folders[0].Files[0].ReadRange(2, 5)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if string(b) != "gocab" {
+		t.Fatalf("expected %q, but got %q", "gocab", b)
+	}
+}
+
+func TestFilePeek(t *testing.T) {
+	big := make([]byte, 100000)
+	for i := range big {
+		big[i] = byte(i * 7)
+	}
+	data := buildMSZIPCab([]testFile{
+		{name: "big.bin", data: big},
+		{name: "small.txt", data: []byte("small")},
+	})
+
+	// corrupt the last block, which holds the end of big.bin, so that decoding it fails
+	off := int(binary.LittleEndian.Uint32(data[36:]))
+	for i := 0; i < int(binary.LittleEndian.Uint16(data[40:]))-1; i++ {
+		off += 8 + int(binary.LittleEndian.Uint16(data[off+4:]))
+	}
+	copy(data[off+8:], "XX")
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	file := r.Folders[0].Files[0]
+
+	for _, n := range []int{0, 4, 32768, 40000} {
+		b, err := file.Peek(n)
+		if err != nil {
+			t.Fatalf("expected no error peeking %d bytes, but got %v", n, err)
+		}
+		if !bytes.Equal(b, big[:n]) {
+			t.Fatalf("unexpected content peeking %d bytes", n)
+		}
+	}
+
+	if _, err := file.Peek(len(big)); err == nil {
+		t.Fatalf("expected an error peeking into the corrupt block, but got none")
+	}
+	if _, err := file.Peek(-1); err == nil {
+		t.Fatalf("expected an error peeking a negative count, but got none")
+	}
+
+	t.Run("small", func(t *testing.T) {
+		data := buildCab([]testFile{{name: "small.txt", data: []byte("small")}})
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		b, err := r.File[0].Peek(512)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != "small" {
+			t.Fatalf("expected %q, but got %q", "small", b)
+		}
+	})
+}
+
+func TestReaderFilesSorted(t *testing.T) {
+	a := testFile{name: `dir\a.txt`, data: []byte("a")}
+	b := testFile{name: "b.txt", data: []byte("b")}
+	c := testFile{name: `dir\sub\c.txt`, data: []byte("c")}
+	d := testFile{name: "d.txt"}
+
+	cabs := [][]byte{
+		buildCab([]testFile{a, b, c, d}),
+		buildCab([]testFile{d, c}, []testFile{b}, []testFile{a}),
+		buildCab([]testFile{b}, []testFile{c, a, d}),
+	}
+
+	expected := []string{"b.txt", "d.txt", `dir\a.txt`, `dir\sub\c.txt`}
+	for i, data := range cabs {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		var names []string
+		for _, file := range r.FilesSorted() {
+			names = append(names, file.Name)
+		}
+
+		if !reflect.DeepEqual(names, expected) {
+			t.Fatalf("expected %v for cab %d, but got %v", expected, i, names)
+		}
+	}
+}
+
+func TestReaderFilesByTopDir(t *testing.T) {
+	data := buildCab(
+		[]testFile{
+			{name: `docs\readme.txt`, data: []byte("readme")},
+			{name: "setup.exe", data: []byte("setup")},
+			{name: `bin\tool.exe`, data: []byte("tool")},
+		},
+		[]testFile{
+			{name: "docs/guide/intro.txt", data: []byte("intro")},
+			{name: `bin\`},
+			{name: "license.txt", data: []byte("license")},
+		},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string][]string{
+		"":     {"setup.exe", "license.txt"},
+		"bin":  {`bin\tool.exe`, `bin\`},
+		"docs": {`docs\readme.txt`, "docs/guide/intro.txt"},
+	}
+
+	groups := r.FilesByTopDir()
+	actual := make(map[string][]string, len(groups))
+	for top, files := range groups {
+		for _, file := range files {
+			actual[top] = append(actual[top], file.Name)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Fatalf("expected %q, but got %q", expected, actual)
+	}
+}
+
+func TestReaderTrailingData(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	data := append(append([]byte(nil), readme...), bytes.Repeat([]byte("trailing garbage"), 100)...)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if r.CabinetSize() != int64(len(readme)) {
+		t.Fatalf("expected a size of %d, but got %d", len(readme), r.CabinetSize())
+	}
+
+	if err := r.VerifyChecksums(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	file, ok := r.FileByName("README.md")
+	if !ok {
+		t.Fatalf("expected to find README.md")
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if string(b) != "# gocab\r\nCab archive library for go.\r\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+}
+
+func TestReaderUnusualLayout(t *testing.T) {
+	orig := buildMSZIPCab(
+		[]testFile{{name: "a.txt", data: bytes.Repeat([]byte("a"), 40000)}},
+		[]testFile{{name: "b.txt", data: []byte("b")}, {name: "c.txt", data: []byte("c")}},
+	)
+
+	le := binary.LittleEndian
+	coffFiles := le.Uint32(orig[16:])
+	first := le.Uint32(orig[36:])
+	second := le.Uint32(orig[44:])
+	headerAndFolders := orig[:coffFiles]
+	files := orig[coffFiles:first]
+
+	// lay the cabinet out as header, folders, padding, the second folder's data, the
+	// file table and then the first folder's data
+	var data []byte
+	data = append(data, headerAndFolders...)
+	data = append(data, make([]byte, 16)...)
+	le.PutUint32(data[44:], uint32(len(data)))
+	data = append(data, orig[second:]...)
+	le.PutUint32(data[16:], uint32(len(data)))
+	data = append(data, files...)
+	le.PutUint32(data[36:], uint32(len(data)))
+	data = append(data, orig[first:second]...)
+	le.PutUint32(data[8:], uint32(len(data)))
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string][]byte{
+		"a.txt": bytes.Repeat([]byte("a"), 40000),
+		"b.txt": []byte("b"),
+		"c.txt": []byte("c"),
+	}
+	for name, content := range expected {
+		file, ok := r.FileByName(name)
+		if !ok {
+			t.Fatalf("expected to find %s", name)
+		}
+
+		b, err := file.ReadRange(0, file.Size())
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(b, content) {
+			t.Fatalf("unexpected content for %s", name)
+		}
+	}
+}
+
+func TestReaderFolderIndexBoundary(t *testing.T) {
+	testCases := []struct {
+		name       string
+		numFolders int
+		files      map[int]testFile
+	}{
+		{
+			name:       "highest index below the continuation values",
+			numFolders: 0xfffd,
+			files: map[int]testFile{
+				0:      {name: "first.txt", data: []byte("first")},
+				0xfffc: {name: "last.txt", data: []byte("last")},
+			},
+		},
+		{
+			name:       "continuation values in an unlinked cabinet",
+			numFolders: 0xffff,
+			files: map[int]testFile{
+				0:      {name: "first.txt", data: []byte("first")},
+				0xfffd: {name: "fffd.txt", data: []byte("fffd")},
+				0xfffe: {name: "fffe.txt", data: []byte("fffe")},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			folders := make([][]testFile, tc.numFolders)
+			for i, f := range tc.files {
+				folders[i] = []testFile{f}
+			}
+			data := buildCab(folders...)
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			if len(r.Folders) != tc.numFolders {
+				t.Fatalf("expected %d folders, but got %d", tc.numFolders, len(r.Folders))
+			}
+
+			for i, f := range tc.files {
+				files := r.Folders[i].Files
+				if len(files) != 1 || files[0].Name != f.name {
+					t.Fatalf("expected folder %d to hold only %s", i, f.name)
+				}
+				if b := readFile(t, files[0]); !bytes.Equal(b, f.data) {
+					t.Fatalf("expected %q, but got %q", f.data, b)
+				}
+			}
+		})
+	}
+
+	t.Run("continuation value in an unlinked cabinet", func(t *testing.T) {
+		data := buildCab([]testFile{{name: "a.txt", data: []byte("a")}})
+		binary.LittleEndian.PutUint16(data[52:], 0xfffd)
+
+		if _, err := cab.NewReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, cab.ErrCorrupt) {
+			t.Fatalf("expected %v to match %v", err, cab.ErrCorrupt)
+		}
+	})
+
+	t.Run("continuation values in a linked cabinet", func(t *testing.T) {
+		data := buildCab(
+			[]testFile{{name: "first.txt", data: []byte("first")}, {name: "next.txt", data: []byte("next")}},
+			[]testFile{{name: "prev.txt", data: []byte("prev")}},
+		)
+		// move the second file to the last folder and the third to the first
+		binary.LittleEndian.PutUint16(data[86:], 0xfffe)
+		binary.LittleEndian.PutUint16(data[111:], 0xfffd)
+		data = linkVolume(data, true, true)
+
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		for i, expected := range [][]string{{"first.txt", "prev.txt"}, {"next.txt"}} {
+			var names []string
+			for _, file := range r.Folders[i].Files {
+				names = append(names, file.Name)
+			}
+			if !reflect.DeepEqual(names, expected) {
+				t.Fatalf("expected folder %d to hold %v, but got %v", i, expected, names)
+			}
+		}
+	})
+
+	t.Run("continued from the previous and into the next cabinet", func(t *testing.T) {
+		testCases := []struct {
+			name    string
+			folders [][]testFile
+			valid   bool
+		}{
+			{
+				name:    "one folder",
+				folders: [][]testFile{{{name: "both.txt", data: []byte("both")}}},
+				valid:   true,
+			},
+			{
+				name: "two folders",
+				folders: [][]testFile{
+					{{name: "both.txt", data: []byte("both")}},
+					{{name: "other.txt", data: []byte("other")}},
+				},
+			},
+		}
+
+		for _, tc := range testCases {
+			t.Run(tc.name, func(t *testing.T) {
+				data := buildCab(tc.folders...)
+				// iFolder of the first file, which follows the folder table
+				binary.LittleEndian.PutUint16(data[36+8*len(tc.folders)+8:], 0xffff)
+				data = linkVolume(data, true, true)
+
+				r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+				if !tc.valid {
+					if !errors.Is(err, cab.ErrCorrupt) {
+						t.Fatalf("expected %v to match %v", err, cab.ErrCorrupt)
+					}
+					return
+				}
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if !r.Folders[0].ContinuesFromPrev() || !r.Folders[0].ContinuesToNext() {
+					t.Fatalf("expected the folder to continue both ways")
+				}
+			})
+		}
+	})
+}
+
+func TestReaderReset(t *testing.T) {
+	first := linkVolume(buildCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}},
+		[]testFile{{name: "b.txt", data: []byte("b")}},
+	), true, false)
+	second := buildMSZIPCab([]testFile{{name: "c.txt", data: []byte("ccc")}})
+
+	r, err := cab.NewReader(bytes.NewReader(first), int64(len(first)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if r.PrevCab == nil || len(r.Folders) != 2 {
+		t.Fatalf("expected a linked cabinet with 2 folders")
+	}
+
+	if err := r.Reset(bytes.NewReader(second), int64(len(second))); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if r.PrevCab != nil {
+		t.Fatalf("expected PrevCab to be cleared, but got %+v", r.PrevCab)
+	}
+	if len(r.Folders) != 1 {
+		t.Fatalf("expected 1 folder, but got %d", len(r.Folders))
+	}
+	if _, ok := r.FileByName("a.txt"); ok {
+		t.Fatalf("expected to not find a.txt")
+	}
+	file, ok := r.FileByName("c.txt")
+	if !ok {
+		t.Fatalf("expected to find c.txt")
+	}
+	if b := readFile(t, file); string(b) != "ccc" {
+		t.Fatalf("expected %q, but got %q", "ccc", b)
+	}
+
+	if err := r.Reset(bytes.NewReader(nil), 0); !errors.Is(err, cab.ErrNotCabinet) {
+		t.Fatalf("expected %v to match %v", err, cab.ErrNotCabinet)
+	}
+	if err := r.Reset(bytes.NewReader(first), -1); err == nil {
+		t.Fatalf("expected an error, but got none")
+	}
+}
+
+func TestReadCloserReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "a.cab")
+	if err := ioutil.WriteFile(path, buildCab([]testFile{{name: "a.txt", data: []byte("a")}}), 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.OpenReader(path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer r.Close()
+
+	// rewrite the file in place with a larger cabinet
+	rewritten := buildMSZIPCab(
+		[]testFile{{name: "b.txt", data: []byte("bbb")}},
+		[]testFile{{name: "c.txt", data: bytes.Repeat([]byte("c"), 1000)}},
+	)
+	if err := ioutil.WriteFile(path, rewritten, 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.Reload(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if len(r.Folders) != 2 || r.CabinetSize() != int64(len(rewritten)) {
+		t.Fatalf("expected the rewritten cabinet, but got %d folders of %d bytes", len(r.Folders), r.CabinetSize())
+	}
+	if _, ok := r.FileByName("a.txt"); ok {
+		t.Fatalf("expected to not find a.txt")
+	}
+	file, ok := r.FileByName("c.txt")
+	if !ok {
+		t.Fatalf("expected to find c.txt")
+	}
+	if b := readFile(t, file); !bytes.Equal(b, bytes.Repeat([]byte("c"), 1000)) {
+		t.Fatalf("unexpected content for c.txt")
+	}
+
+	if err := ioutil.WriteFile(path, []byte("not a cabinet"), 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := r.Reload(); !errors.Is(err, cab.ErrNotCabinet) {
+		t.Fatalf("expected %v to match %v", err, cab.ErrNotCabinet)
+	}
+}
+
+func TestNewReaderFromSeeker(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	testCases := []struct {
+		name string
+		rs   io.ReadSeeker
+	}{
+		{name: "reader at", rs: bytes.NewReader(readme)},
+		// hide ReadAt so that reads go through Seek and Read
+		{name: "seeker only", rs: struct{ io.ReadSeeker }{bytes.NewReader(readme)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := cab.NewReaderFromSeeker(tc.rs)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			file, ok := r.FileByName("README.md")
+			if !ok {
+				t.Fatalf("expected to find README.md")
+			}
+			if b := readFile(t, file); string(b) != "# gocab\r\nCab archive library for go.\r\n" {
+				t.Fatalf("unexpected content %q", b)
+			}
+		})
+	}
+}
+
+func TestReaderMaxCounts(t *testing.T) {
+	const max = 0xffff
+
+	folders := make([][]testFile, max)
+	for i := range folders {
+		folders[i] = []testFile{{name: fmt.Sprintf("%05d.txt", i), data: []byte{byte(i)}}}
+	}
+	data := buildCab(folders...)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if len(r.Folders) != max {
+		t.Fatalf("expected %d folders, but got %d", max, len(r.Folders))
+	}
+	if files := r.FilesSorted(); len(files) != max {
+		t.Fatalf("expected %d files, but got %d", max, len(files))
+	}
+
+	// without continuation flags, the largest folder indexes are ordinary indexes
+	for _, i := range []int{0, 0xfffc, 0xfffd, 0xfffe} {
+		folder := r.Folders[i]
+		if len(folder.Files) != 1 {
+			t.Fatalf("expected folder %d to have 1 file, but got %d", i, len(folder.Files))
+		}
+		file := folder.Files[0]
+		if expected := fmt.Sprintf("%05d.txt", i); file.Name != expected {
+			t.Fatalf("expected %s in folder %d, but got %s", expected, i, file.Name)
+		}
+		if b := readFile(t, file); !bytes.Equal(b, []byte{byte(i)}) {
+			t.Fatalf("unexpected content for %s", file.Name)
+		}
+	}
+}
+
+func TestReaderLenientReserve(t *testing.T) {
+	files := []testFile{
+		{name: "a.txt", data: []byte("aaa")},
+		{name: "b.txt", data: []byte("bb")},
+	}
+	le := binary.LittleEndian
+
+	// the reserve present flag is clear although the header has reserve fields
+	missingFlag := withDataReserve(buildCab(files), 3)
+	le.PutUint16(missingFlag[30:], le.Uint16(missingFlag[30:])&^0x4)
+
+	// the reserve present flag is set although the header has no reserve fields
+	extraFlag := buildCab(files)
+	le.PutUint16(extraFlag[30:], le.Uint16(extraFlag[30:])|0x4)
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		warnings int
+	}{
+		{name: "consistent", data: withDataReserve(buildCab(files), 3)},
+		{name: "missing flag", data: missingFlag, warnings: 1},
+		{name: "extra flag", data: extraFlag, warnings: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := cab.NewReader(bytes.NewReader(tc.data), int64(len(tc.data)), cab.WithLenientReserve())
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if warnings := r.Warnings(); len(warnings) != tc.warnings {
+				t.Fatalf("expected %d warning(s), but got %v", tc.warnings, warnings)
+			}
+
+			for i, file := range r.Folders[0].Files {
+				if file.Name != files[i].name {
+					t.Fatalf("expected %s, but got %s", files[i].name, file.Name)
+				}
+				if b := readFile(t, file); !bytes.Equal(b, files[i].data) {
+					t.Fatalf("expected %q for %s, but got %q", files[i].data, file.Name, b)
+				}
+			}
+
+			if tc.warnings == 0 {
+				return
+			}
+
+			// without the option, the flag is followed and the cabinet is misread
+			r, err = cab.NewReader(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err != nil {
+				return
+			}
+			rc, err := r.File[0].Open()
+			if err != nil {
+				return
+			}
+			if b, err := ioutil.ReadAll(rc); err != nil || !bytes.Equal(b, files[0].data) {
+				return
+			}
+			t.Fatalf("expected the cabinet to be misread without the option")
+		})
+	}
+}
+
+func TestReaderTrailingDataExtract(t *testing.T) {
+	cabinet := buildMSZIPCab([]testFile{
+		{name: "a.txt", data: []byte("aaa")},
+		{name: `sub\b.txt`, data: []byte("bb")},
+	})
+	data := append(append([]byte(nil), cabinet...), bytes.Repeat([]byte("junk"), 100)...)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	for name, content := range map[string]string{"a.txt": "aaa", filepath.Join("sub", "b.txt"): "bb"} {
+		if b, err := ioutil.ReadFile(filepath.Join(dir, name)); err != nil || string(b) != content {
+			t.Fatalf("expected %q for %s, but got %q and %v", content, name, b, err)
+		}
+	}
+
+	// the last block may not extend into the trailing data
+	le := binary.LittleEndian
+	truncated := append([]byte(nil), data...)
+	le.PutUint32(truncated[8:], le.Uint32(truncated[8:])-1)
+	r, err = cab.NewReader(bytes.NewReader(truncated), int64(len(truncated)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	rc, err := r.File[1].Open()
+	if err == nil {
+		_, err = ioutil.ReadAll(rc)
+	}
+	if !errors.Is(err, cab.ErrTruncated) {
+		t.Fatalf("expected a truncated error, but got %v", err)
+	}
+}
+
+func TestReaderReserveAndRefs(t *testing.T) {
+	le := binary.LittleEndian
+	content := []byte("hello, world")
+	refs := "prev.cab\x00disk 1\x00next.cab\x00disk 3\x00"
+
+	const cabinetReserve, folderReserve, dataReserve = 6, 2, 3
+	coffFiles := 36 + 4 + cabinetReserve + len(refs) + 8 + folderReserve
+	fileEntry := 16 + len("a.txt") + 1
+	dataOffset := coffFiles + fileEntry
+
+	var b bytes.Buffer
+	b.WriteString("MSCF")
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint32(dataOffset+8+dataReserve+len(content)))
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint32(coffFiles))
+	binary.Write(&b, le, uint32(0))
+	b.Write([]byte{3, 1})
+	binary.Write(&b, le, uint16(1))   // folders
+	binary.Write(&b, le, uint16(1))   // files
+	binary.Write(&b, le, uint16(0x7)) // prev, next and reserve present
+	binary.Write(&b, le, uint16(0))
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, uint16(cabinetReserve))
+	b.Write([]byte{folderReserve, dataReserve})
+	b.Write(bytes.Repeat([]byte{0xaa}, cabinetReserve))
+	b.WriteString(refs)
+	binary.Write(&b, le, uint32(dataOffset))
+	binary.Write(&b, le, uint16(1))
+	binary.Write(&b, le, uint16(0))
+	b.Write(bytes.Repeat([]byte{0xbb}, folderReserve))
+	binary.Write(&b, le, uint32(len(content)))
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint16(0))
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint16(0))
+	b.WriteString("a.txt\x00")
+	binary.Write(&b, le, uint32(0))
+	binary.Write(&b, le, uint16(len(content)))
+	binary.Write(&b, le, uint16(len(content)))
+	b.Write(bytes.Repeat([]byte{0xcc}, dataReserve))
+	b.Write(content)
+	data := b.Bytes()
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if expected := (&cab.Ref{Name: "prev.cab", Disk: "disk 1"}); !reflect.DeepEqual(r.PrevCab, expected) {
+		t.Fatalf("expected %+v, but got %+v", expected, r.PrevCab)
+	}
+	if expected := (&cab.Ref{Name: "next.cab", Disk: "disk 3"}); !reflect.DeepEqual(r.NextCab, expected) {
+		t.Fatalf("expected %+v, but got %+v", expected, r.NextCab)
+	}
+	if expected := bytes.Repeat([]byte{0xbb}, folderReserve); !bytes.Equal(r.Folders[0].Reserve, expected) {
+		t.Fatalf("expected folder reserve %x, but got %x", expected, r.Folders[0].Reserve)
+	}
+	if len(r.File) != 1 || r.File[0].Name != "a.txt" {
+		t.Fatalf("expected a.txt, but got %v", r.File)
+	}
+	if b := readFile(t, r.File[0]); !bytes.Equal(b, content) {
+		t.Fatalf("expected %q, but got %q", content, b)
+	}
+}
+
+func TestFolderReserve(t *testing.T) {
+	le := binary.LittleEndian
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("aa")}},
+		[]testFile{{name: "b.txt", data: []byte("bb")}, {name: "c.txt", data: []byte("cc")}},
+	)
+
+	t.Run("none", func(t *testing.T) {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		for i, folder := range r.Folders {
+			if folder.Reserve != nil {
+				t.Fatalf("expected folder %d to have no reserve, but got %x", i, folder.Reserve)
+			}
+		}
+	})
+
+	t.Run("reserved", func(t *testing.T) {
+		reserves := [][]byte{[]byte("first!"), []byte("second")}
+		// add the reserve sizes to the header and a reserve to each folder entry,
+		// moving everything after them along
+		shift := uint32(4 + len(reserves[0]) + len(reserves[1]))
+		out := append([]byte(nil), data[:36]...)
+		le.PutUint16(out[30:], le.Uint16(out[30:])|0x4)
+		le.PutUint32(out[8:], le.Uint32(out[8:])+shift)
+		le.PutUint32(out[16:], le.Uint32(out[16:])+shift)
+		out = append(out, 0, 0, byte(len(reserves[0])), 0)
+		for i, reserve := range reserves {
+			entry := append([]byte(nil), data[36+8*i:44+8*i]...)
+			le.PutUint32(entry, le.Uint32(entry)+shift)
+			out = append(out, entry...)
+			out = append(out, reserve...)
+		}
+		out = append(out, data[52:]...)
+
+		r, err := cab.NewReader(bytes.NewReader(out), int64(len(out)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		for i, folder := range r.Folders {
+			if !bytes.Equal(folder.Reserve, reserves[i]) {
+				t.Fatalf("expected folder %d to have reserve %q, but got %q", i, reserves[i], folder.Reserve)
+			}
+		}
+		for _, file := range r.File {
+			if b := readFile(t, file); len(b) != 2 || b[0] != file.Name[0] {
+				t.Fatalf("unexpected content %q for %s", b, file.Name)
+			}
+		}
+	})
+}
+
+func TestReaderNoFolders(t *testing.T) {
+	t.Run("no files", func(t *testing.T) {
+		data := buildCab()
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if r.NumFolders() != 0 || r.NumFiles() != 0 {
+			t.Fatalf("expected no folders or files, but got %d and %d", r.NumFolders(), r.NumFiles())
+		}
+
+		dir, err := ioutil.TempDir("", "cab")
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		defer os.RemoveAll(dir)
+
+		if err := r.ExtractTo(dir); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	})
+
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "file", data: buildCab([]testFile{{name: "a.txt", data: []byte("a")}})},
+		{name: "continued file", data: buildRawCab(0x1, 0, []rawFile{{name: "a.txt", size: 1, folder: 0xfffd}}, []rawBlock{{data: []byte("a"), uncompressedSize: 1}})},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// cFolders
+			binary.LittleEndian.PutUint16(tc.data[26:], 0)
+
+			_, err := cab.NewReader(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if !errors.Is(err, cab.ErrCorrupt) || errors.Is(err, cab.ErrTruncated) {
+				t.Fatalf("expected ErrCorrupt, but got %v", err)
+			}
+			if !strings.Contains(err.Error(), "no folders") {
+				t.Fatalf("expected the error to say the cabinet has no folders, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestReaderFolderOf(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}, {name: "b.txt", data: []byte("b")}},
+		[]testFile{{name: "c.txt", data: []byte("c")}},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	other, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	for i, expected := range []int{0, 0, 1} {
+		file := r.File[i]
+		idx, folder, ok := r.FolderOf(file)
+		if !ok {
+			t.Fatalf("expected %s to belong to the reader", file.Name)
+		}
+		if idx != expected || folder != r.Folders[expected] {
+			t.Fatalf("expected %s to be in folder %d, but got %d", file.Name, expected, idx)
+		}
+
+		if _, _, ok := other.FolderOf(file); ok {
+			t.Fatalf("expected %s to not belong to another reader", file.Name)
+		}
+	}
+
+	if _, _, ok := r.FolderOf(nil); ok {
+		t.Fatalf("expected a nil file to not belong to the reader")
+	}
+}
+
+func TestReaderSummary(t *testing.T) {
+	data := buildCab(
+		[]testFile{{name: "a.txt", data: []byte("aa")}, {name: "b.txt", data: []byte("b")}},
+		[]testFile{{name: "c.txt", data: []byte("ccc")}},
+	)
+
+	testCases := []struct {
+		name     string
+		numFiles uint16
+		opts     []cab.ReaderOption
+		expected cab.Summary
+	}{
+		{name: "all", numFiles: 3, expected: cab.Summary{Files: 3, Folders: 2, UncompressedSize: 6}},
+		{name: "fewer declared", numFiles: 2, expected: cab.Summary{Files: 2, Folders: 2, UncompressedSize: 3}},
+		{name: "header only", numFiles: 3, opts: []cab.ReaderOption{cab.WithHeaderOnly()}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := append([]byte(nil), data...)
+			// cFiles
+			binary.LittleEndian.PutUint16(data[28:], tc.numFiles)
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), tc.opts...)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			if actual := r.Summary(); actual != tc.expected {
+				t.Fatalf("expected %+v, but got %+v", tc.expected, actual)
+			}
+			if r.NumFiles() != len(r.File) {
+				t.Fatalf("expected %d files, but got %d", len(r.File), r.NumFiles())
+			}
+			if r.NumFolders() != len(r.Folders) {
+				t.Fatalf("expected %d folders, but got %d", len(r.Folders), r.NumFolders())
+			}
+		})
+	}
+}
+
+func TestReaderIsForwardReadable(t *testing.T) {
+	swapFolders := func(data []byte) []byte {
+		data = append([]byte(nil), data...)
+		var first [8]byte
+		copy(first[:], data[36:44])
+		copy(data[36:44], data[44:52])
+		copy(data[44:52], first[:])
+		return data
+	}
+	block := []rawBlock{{data: []byte("abcdef"), uncompressedSize: 6}}
+
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected bool
+	}{
+		{
+			name: "in order",
+			data: buildCab(
+				[]testFile{{name: "a.txt", data: []byte("aa")}, {name: "b.txt", data: []byte("bb")}},
+				[]testFile{{name: "c.txt", data: []byte("cc")}},
+			),
+			expected: true,
+		},
+		{
+			name:     "empty",
+			data:     buildCab(),
+			expected: true,
+		},
+		{
+			name: "folders out of data order",
+			data: swapFolders(buildCab(
+				[]testFile{{name: "a.txt", data: []byte("aa")}},
+				[]testFile{{name: "b.txt", data: []byte("bb")}},
+			)),
+		},
+		{
+			name: "files out of stream order",
+			data: buildRawCab(0, 0, []rawFile{
+				{name: "b.txt", size: 3, offset: 3},
+				{name: "a.txt", size: 3, offset: 0},
+			}, block),
+		},
+		{
+			name: "overlapping files",
+			data: buildRawCab(0, 0, []rawFile{
+				{name: "a.txt", size: 4, offset: 0},
+				{name: "b.txt", size: 4, offset: 2},
+			}, block),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := cab.NewReader(bytes.NewReader(tc.data), int64(len(tc.data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if actual := r.IsForwardReadable(); actual != tc.expected {
+				t.Fatalf("expected %v, but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestRefString(t *testing.T) {
+	testCases := []struct {
+		ref      cab.Ref
+		expected string
+	}{
+		{ref: cab.Ref{Disk: "disk2", Name: "data2.cab"}, expected: "disk2: data2.cab"},
+		{ref: cab.Ref{Name: "data2.cab"}, expected: "data2.cab"},
+	}
+
+	for _, tc := range testCases {
+		if actual := tc.ref.String(); actual != tc.expected {
+			t.Fatalf("expected %q, but got %q", tc.expected, actual)
+		}
+	}
+
+	data := linkVolume(buildCab([]testFile{{name: "a.txt", data: []byte("a")}}), true, true)
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if actual := fmt.Sprintf("%v -> %v", r.PrevCab, r.NextCab); actual != "prev.cab -> next.cab" {
+		t.Fatalf("expected %q, but got %q", "prev.cab -> next.cab", actual)
+	}
+}
+
+func TestReaderHeaderOnly(t *testing.T) {
+	data := linkVolume(buildVolume(0x1234, 2, testFile{name: "a.txt", data: []byte("a")}), true, true)
+	// point the file table past the end, which only a full parse would notice
+	binary.LittleEndian.PutUint32(data[16:], uint32(len(data)+100))
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "set.cab")
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if _, err := cab.OpenReader(path); !errors.Is(err, cab.ErrCorrupt) {
+		t.Fatalf("expected ErrCorrupt without WithHeaderOnly, but got %v", err)
+	}
+
+	r, err := cab.OpenReader(path, cab.WithHeaderOnly())
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer r.Close()
+
+	if r.Version() != (cab.Version{Major: 1, Minor: 3}) {
+		t.Fatalf("expected version 1.3, but got %v", r.Version())
+	}
+	if r.SetID() != 0x1234 || r.SetIndex() != 2 {
+		t.Fatalf("expected set 0x1234 index 2, but got %#x index %d", r.SetID(), r.SetIndex())
+	}
+	if r.PrevCab == nil || r.NextCab == nil {
+		t.Fatalf("expected previous and next cabinets, but got %v and %v", r.PrevCab, r.NextCab)
+	}
+	if len(r.Folders) != 0 || len(r.File) != 0 {
+		t.Fatalf("expected no folders or files, but got %d and %d", len(r.Folders), len(r.File))
+	}
+
+	if err := r.ExtractTo(dir); !errors.Is(err, cab.ErrHeaderOnly) {
+		t.Fatalf("expected ErrHeaderOnly, but got %v", err)
+	}
+	if err := r.VerifyChecksums(); !errors.Is(err, cab.ErrHeaderOnly) {
+		t.Fatalf("expected ErrHeaderOnly, but got %v", err)
+	}
+	if _, err := r.Open("a.txt"); !errors.Is(err, cab.ErrHeaderOnly) {
+		t.Fatalf("expected ErrHeaderOnly, but got %v", err)
+	}
+}
+
+func BenchmarkNewReaderHeaderOnly(b *testing.B) {
+	var files []testFile
+	for i := 0; i < 50000; i++ {
+		files = append(files, testFile{name: fmt.Sprintf(`dir\file%05d.txt`, i)})
+	}
+	data := buildCab(files)
+
+	testCases := []struct {
+		name string
+		opts []cab.ReaderOption
+	}{
+		{name: "full"},
+		{name: "header only", opts: []cab.ReaderOption{cab.WithHeaderOnly()}},
+	}
+
+	for _, tc := range testCases {
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := cab.NewReader(bytes.NewReader(data), int64(len(data)), tc.opts...); err != nil {
+					b.Fatalf("expected no error, but got %v", err)
+				}
+			}
+		})
+	}
+}
+
+// countingReaderAt counts the calls to ReadAt of the io.ReaderAt it wraps.
+type countingReaderAt struct {
+	r     io.ReaderAt
+	reads int64
+}
+
+func (c *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	c.reads++
+	return c.r.ReadAt(p, off)
+}
+
+func TestReaderReadBufferSize(t *testing.T) {
+	var files []testFile
+	for i := 0; i < 1000; i++ {
+		files = append(files, testFile{name: fmt.Sprintf(`dir\file%04d.txt`, i), data: []byte{byte(i)}})
+	}
+	data := buildCab(files)
+
+	var prevReads int64
+	for _, size := range []int{0, 16, 4096, 64 << 10} {
+		cr := &countingReaderAt{r: bytes.NewReader(data)}
+		r, err := cab.NewReader(cr, int64(len(data)), cab.WithReadBufferSize(size))
+		if err != nil {
+			t.Fatalf("expected no error with a %d byte buffer, but got %v", size, err)
+		}
+		if len(r.File) != len(files) || r.File[999].Name != `dir\file0999.txt` {
+			t.Fatalf("expected %d files with a %d byte buffer, but got %d", len(files), size, len(r.File))
+		}
+		if prevReads != 0 && cr.reads > prevReads {
+			t.Fatalf("expected no more than %d reads with a %d byte buffer, but got %d", prevReads, size, cr.reads)
+		}
+		prevReads = cr.reads
+	}
+}
+
+func BenchmarkNewReaderReadBufferSize(b *testing.B) {
+	var files []testFile
+	for i := 0; i < 50000; i++ {
+		files = append(files, testFile{name: fmt.Sprintf(`dir\file%05d.txt`, i)})
+	}
+	data := buildCab(files)
+
+	for _, size := range []int{512, cab.DefaultReadBufferSize, 64 << 10, 1 << 20} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.ReportAllocs()
+			var reads int64
+			for i := 0; i < b.N; i++ {
+				cr := &countingReaderAt{r: bytes.NewReader(data)}
+				if _, err := cab.NewReader(cr, int64(len(data)), cab.WithReadBufferSize(size)); err != nil {
+					b.Fatalf("expected no error, but got %v", err)
+				}
+				reads += cr.reads
+			}
+			b.ReportMetric(float64(reads)/float64(b.N), "reads/op")
+		})
+	}
+}
+
+func TestReaderOpenInFolder(t *testing.T) {
+	data := buildMSZIPCab(
+		[]testFile{{name: "a.txt", data: []byte("a")}, {name: "b.txt", data: []byte("bb")}},
+		[]testFile{{name: "c.txt", data: []byte("ccc")}},
+	)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	testCases := []struct {
+		folder, file int
+		expected     string
+	}{
+		{folder: 0, file: 0, expected: "a"},
+		{folder: 0, file: 1, expected: "bb"},
+		{folder: 1, file: 0, expected: "ccc"},
+	}
+
+	for _, tc := range testCases {
+		rc, err := r.OpenInFolder(tc.folder, tc.file)
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != tc.expected {
+			t.Fatalf("expected %q, but got %q", tc.expected, b)
+		}
+	}
+
+	for _, idx := range [][2]int{{-1, 0}, {2, 0}, {0, -1}, {0, 2}, {1, 1}} {
+		if _, err := r.OpenInFolder(idx[0], idx[1]); err == nil {
+			t.Fatalf("expected an error opening folder %d file %d", idx[0], idx[1])
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package cab
+
+import "sort"
+
+// Region is a range of bytes in a cabinet file.
+type Region struct {
+	Offset int64
+	Length int64
+}
+
+// UnreferencedRegions returns the ranges of the cabinet file, in order, that are not
+// part of its header, its folder or file tables or any folder's data blocks: slack
+// space between them and anything appended after the size declared in the header.
+// Extraction ignores these bytes, so they may hide data. Only the block headers are
+// read; the blocks of a folder after one whose header cannot be read are reported as
+// unreferenced.
+func (c *Reader) UnreferencedRegions() []Region {
+	used := []Region{{Offset: 0, Length: c.headerEnd}, c.fileTable}
+	for _, folder := range c.Folders {
+		index, _ := c.blockIndex(folder)
+		for _, e := range index {
+			length := dataHeaderSize + int64(c.dataReserveSize) + int64(e.compressedSize)
+			used = append(used, Region{Offset: e.offset, Length: length})
+		}
+	}
+
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].Offset < used[j].Offset
+	})
+
+	var regions []Region
+	var pos int64
+	for _, u := range used {
+		if u.Offset > pos {
+			regions = append(regions, Region{Offset: pos, Length: u.Offset - pos})
+		}
+		if end := u.Offset + u.Length; end > pos {
+			pos = end
+		}
+	}
+	if pos < c.fileSize {
+		regions = append(regions, Region{Offset: pos, Length: c.fileSize - pos})
+	}
+
+	return regions
+}
@@ -0,0 +1,63 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderUnreferencedRegions(t *testing.T) {
+	data := buildCab([]testFile{
+		{name: "a.txt", data: []byte("aaa")},
+		{name: "b.txt", data: []byte("bbb")},
+	})
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if regions := r.UnreferencedRegions(); len(regions) != 0 {
+		t.Fatalf("expected no unreferenced regions, but got %+v", regions)
+	}
+
+	// hide data between the file table and the data blocks, and after the cabinet
+	le := binary.LittleEndian
+	dataOffset := le.Uint32(data[36:])
+	slack := []byte("slack space")
+	appended := []byte("appended payload")
+
+	var hidden []byte
+	hidden = append(hidden, data[:dataOffset]...)
+	hidden = append(hidden, slack...)
+	hidden = append(hidden, data[dataOffset:]...)
+	le.PutUint32(hidden[8:], uint32(len(hidden)))
+	le.PutUint32(hidden[36:], dataOffset+uint32(len(slack)))
+	hidden = append(hidden, appended...)
+
+	r, err = cab.NewReader(bytes.NewReader(hidden), int64(len(hidden)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := []cab.Region{
+		{Offset: int64(dataOffset), Length: int64(len(slack))},
+		{Offset: int64(len(hidden) - len(appended)), Length: int64(len(appended))},
+	}
+	regions := r.UnreferencedRegions()
+	if len(regions) != len(expected) {
+		t.Fatalf("expected %+v, but got %+v", expected, regions)
+	}
+	for i, e := range expected {
+		if regions[i] != e {
+			t.Fatalf("expected %+v, but got %+v", e, regions[i])
+		}
+	}
+
+	for _, file := range r.Folders[0].Files {
+		if b := readFile(t, file); len(b) != 3 {
+			t.Fatalf("expected the files to be unaffected, but got %q", b)
+		}
+	}
+}
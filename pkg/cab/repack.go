@@ -0,0 +1,181 @@
+package cab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sort"
+)
+
+// Repack writes the files of src to dst as a new cabinet with a single folder,
+// compressed as specified by opts. File names, sizes, times and attributes are kept.
+//
+// A folder of src that already uses the target compression, if it is one this package
+// encodes itself, is copied without being decompressed: its data blocks are written
+// through byte for byte, with only their offsets and checksums recomputed. Other
+// folders, including those using a compressor added by RegisterCompressor, are
+// decompressed and compressed again. Copied MSZIP blocks keep their original
+// boundaries, so the block before each copied folder may be shorter than the usual
+// 32KB.
+func Repack(dst io.Writer, src *Reader, opts ...WriterOption) error {
+	if err := src.checkFiles(); err != nil {
+		return err
+	}
+
+	w := NewWriter(dst, opts...)
+	if w.err != nil {
+		return w.err
+	}
+
+	for _, folder := range src.Folders {
+		var err error
+		if w.canCopy(folder) {
+			err = w.copyFolder(folder, nil)
+		} else {
+			err = w.recompressFolder(folder, nil)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+// canCopy reports whether the blocks of folder can be added by copyFolder: it must use
+// the Writer's compression, which must be built in, since the state of a registered
+// Compressor cannot account for blocks it did not encode, and must not continue
+// across cabinets.
+func (w *Writer) canCopy(folder *Folder) bool {
+	return folder.compressionType == w.opts.compression && w.comp == nil && folder.prev == nil && folder.next == nil
+}
+
+// copyFolder adds the files of folder, copying its data blocks verbatim. Files are
+// added under their own names unless given another by names; see repackName.
+func (w *Writer) copyFolder(folder *Folder, names map[*File]string) error {
+	index, err := folder.c.blockIndex(folder)
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, e := range index {
+		size += int64(e.uncompressedSize)
+	}
+	if w.size+size > maxFolderSize {
+		return errors.New("cab: folder too large")
+	}
+
+	if err := w.FlushBlock(); err != nil {
+		return err
+	}
+	// the copied data is never decoded here, so blocks compressed after it must not
+	// refer back into it
+	w.window = w.window[:0]
+
+	for _, file := range folder.Files {
+		if int64(file.uncompressedOffset)+int64(file.uncompressedSize) > size {
+			return fmt.Errorf("cab: repacking %q: %w", file.DecodeName(), newCorruptError("file extends beyond its folder"))
+		}
+
+		name, ok := repackName(names, file)
+		if !ok {
+			continue
+		}
+		f, err := w.addRepackedFile(file, name)
+		if err != nil {
+			return err
+		}
+		f.uncompressedOffset = uint32(w.size) + file.uncompressedOffset
+		f.uncompressedSize = file.uncompressedSize
+	}
+
+	var buf []byte
+	for _, e := range index {
+		blk, _, err := folder.c.readDataBlock(e.offset, buf)
+		if err != nil {
+			return err
+		}
+		buf = blk.data
+
+		if err := w.writeBlock(blk.data, int(blk.uncompressedSize)); err != nil {
+			return err
+		}
+	}
+
+	w.size += size
+	return nil
+}
+
+// recompressFolder adds the files of folder, decompressing it once and reading its
+// files in offset order. Files are added under their own names unless given another
+// by names; see repackName.
+func (w *Writer) recompressFolder(folder *Folder, names map[*File]string) error {
+	files := make([]*File, len(folder.Files))
+	copy(files, folder.Files)
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].uncompressedOffset < files[j].uncompressedOffset
+	})
+
+	var fr *folderReader
+	var pos int64
+	for _, file := range files {
+		name, ok := repackName(names, file)
+		if !ok {
+			continue
+		}
+		f, err := w.addRepackedFile(file, name)
+		if err != nil {
+			return err
+		}
+		if file.uncompressedSize == 0 {
+			continue
+		}
+
+		// files may overlap; start the stream over when one begins behind the current position
+		if fr == nil || int64(file.uncompressedOffset) < pos {
+			if fr, err = folder.c.openFolder(folder); err != nil {
+				return err
+			}
+			pos = 0
+		}
+
+		skipped, err := io.CopyN(ioutil.Discard, fr, int64(file.uncompressedOffset)-pos)
+		pos += skipped
+		if err != nil {
+			return fmt.Errorf("cab: repacking %q: %w", file.DecodeName(), truncatedIfEOF(err))
+		}
+
+		n, err := io.CopyN(&fileWriter{w: w, f: f}, fr, int64(file.uncompressedSize))
+		pos += n
+		if err != nil {
+			return fmt.Errorf("cab: repacking %q: %w", file.DecodeName(), truncatedIfEOF(err))
+		}
+	}
+
+	return nil
+}
+
+// repackName returns the name under which file is added, which is its own unless names
+// holds another. It returns false if names maps file to "", leaving it out.
+func repackName(names map[*File]string, file *File) (string, bool) {
+	name, ok := names[file]
+	if !ok {
+		return file.DecodeName(), true
+	}
+	return name, name != ""
+}
+
+// addRepackedFile adds an empty file with the given name and the time and attributes
+// of file.
+func (w *Writer) addRepackedFile(file *File, name string) (*writerFile, error) {
+	f, err := w.addFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("cab: repacking %q: %w", file.DecodeName(), err)
+	}
+
+	f.date, f.time = timeToMsDosTime(file.DateTime)
+	f.attributes |= file.attributes &^ uint16(AttrNameIsUTF)
+	return f, nil
+}
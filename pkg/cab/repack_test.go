@@ -0,0 +1,132 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestRepack(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	folders := [][]testFile{
+		{{name: "a.txt", data: []byte("a")}, {name: `dir\large.bin`, data: large}},
+		{{name: "b.txt", data: []byte("bbb")}, {name: "empty.txt"}},
+	}
+
+	testCases := []struct {
+		name        string
+		src         []byte
+		compression cab.CompressionType
+		verbatim    bool
+	}{
+		{name: "MSZIP verbatim", src: buildMSZIPCab(folders...), compression: cab.CompressionMSZIP, verbatim: true},
+		{name: "stored verbatim", src: buildCab(folders...), compression: cab.CompressionNone, verbatim: true},
+		{name: "MSZIP to stored", src: buildMSZIPCab(folders...), compression: cab.CompressionNone},
+		{name: "stored to MSZIP", src: buildCab(folders...), compression: cab.CompressionMSZIP},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src, err := cab.NewReader(bytes.NewReader(tc.src), int64(len(tc.src)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			var buf bytes.Buffer
+			if err := cab.Repack(&buf, src, cab.WithCompression(tc.compression)); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			out := buf.Bytes()
+
+			r, err := cab.NewReader(bytes.NewReader(out), int64(len(out)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			if len(r.Folders) != 1 {
+				t.Fatalf("expected 1 folder, but got %d", len(r.Folders))
+			}
+			if types := r.CompressionTypesUsed(); len(types) != 1 || types[0] != tc.compression {
+				t.Fatalf("expected only %v to be used, but got %v", tc.compression, types)
+			}
+
+			for _, files := range folders {
+				for _, f := range files {
+					file, ok := r.FileByName(f.name)
+					if !ok {
+						t.Fatalf("expected to find %s", f.name)
+					}
+					if b := readFile(t, file); !bytes.Equal(b, f.data) {
+						t.Fatalf("expected %s to have %d byte(s), but got %d", f.name, len(f.data), len(b))
+					}
+				}
+			}
+
+			if !tc.verbatim {
+				return
+			}
+
+			if err := r.VerifyChecksums(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			// csum of the first data block
+			if csum := binary.LittleEndian.Uint32(out[binary.LittleEndian.Uint32(out[36:]):]); csum == 0 {
+				t.Fatalf("expected the copied blocks to have checksums")
+			}
+
+			for i := range folders {
+				for _, block := range dataBlocks(tc.src, i) {
+					if !bytes.Contains(out, block) {
+						t.Fatalf("expected the data blocks of folder %d to be copied verbatim", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRepackPreservesMetadata(t *testing.T) {
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	src, err := cab.NewReader(bytes.NewReader(readme), int64(len(readme)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := cab.Repack(&buf, src, cab.WithCompression(cab.CompressionMSZIP)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if !reflect.DeepEqual(r.Manifest(), src.Manifest()) {
+		t.Fatalf("expected %v, but got %v", src.Manifest(), r.Manifest())
+	}
+}
+
+// dataBlocks returns the data of each CFDATA entry of a folder in a cabinet without
+// reserves.
+func dataBlocks(data []byte, folder int) [][]byte {
+	le := binary.LittleEndian
+	off := le.Uint32(data[36+8*folder:])
+	numBlocks := le.Uint16(data[36+8*folder+4:])
+
+	var blocks [][]byte
+	for i := 0; i < int(numBlocks); i++ {
+		cbData := uint32(le.Uint16(data[off+4:]))
+		blocks = append(blocks, data[off+8:off+8+cbData])
+		off += 8 + cbData
+	}
+	return blocks
+}
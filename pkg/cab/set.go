@@ -0,0 +1,406 @@
+package cab
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SetReader reads a set of cabinets that together hold a single collection of
+// files. Each cabinet in the set is a volume.
+type SetReader struct {
+	volumes []*Reader
+	closers []*ReadCloser
+}
+
+// OpenSet opens the cabinets specified by names, which make up a set, and returns a
+// SetReader over them. The names may be given in any order. The cabinets are checked as
+// by NewSetReader, and an error names the first cabinet that does not fit the set.
+func OpenSet(names ...string) (*SetReader, error) {
+	closers, err := openVolumes(names)
+	if err != nil {
+		return nil, err
+	}
+	return newOpenedSet(names, closers)
+}
+
+// OpenSetDir opens the cabinets in dir, those whose names end in .cab in any case, and
+// returns a SetReader over them. They must make up one set with no volume missing
+// between the first and the last: if any belong to another set, they are not left out,
+// but reported along with any missing indexes by a *SetMembersError. The set is the one
+// most of the cabinets belong to or, if that is undecided, the one of the cabinet whose
+// name sorts first.
+func OpenSetDir(dir string) (*SetReader, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.EqualFold(filepath.Ext(entry.Name()), ".cab") {
+			names = append(names, filepath.Join(dir, entry.Name()))
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("cab: no cabinets in %s", dir)
+	}
+
+	closers, err := openVolumes(names)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]*Reader, len(closers))
+	for i, c := range closers {
+		volumes[i] = &c.Reader
+	}
+	setID := majoritySetID(volumes)
+
+	merr := &SetMembersError{SetID: setID}
+	indexes := make(map[uint16]bool)
+	var first, last uint16 = math.MaxUint16, 0
+	for i, c := range closers {
+		if c.setID != setID {
+			merr.Outliers = append(merr.Outliers, names[i])
+			continue
+		}
+		indexes[c.setIdx] = true
+		if c.setIdx < first {
+			first = c.setIdx
+		}
+		if c.setIdx > last {
+			last = c.setIdx
+		}
+	}
+	for idx := int(first); idx <= int(last); idx++ {
+		if !indexes[uint16(idx)] {
+			merr.Missing = append(merr.Missing, idx)
+		}
+	}
+	if len(merr.Outliers) > 0 || len(merr.Missing) > 0 {
+		closeVolumes(closers)
+		return nil, merr
+	}
+
+	return newOpenedSet(names, closers)
+}
+
+// SetMembersError reports the cabinets found by OpenSetDir that do not make up one
+// complete set. It matches ErrSetIDMismatch if any cabinet belongs to another set.
+type SetMembersError struct {
+	// SetID is the ID of the set most of the cabinets belong to.
+	SetID uint16
+	// Outliers names the cabinets that belong to other sets.
+	Outliers []string
+	// Missing lists the indexes of the set that no cabinet has, between the lowest
+	// and highest found.
+	Missing []int
+}
+
+func (e *SetMembersError) Error() string {
+	var problems []string
+	if len(e.Outliers) > 0 {
+		problems = append(problems, fmt.Sprintf("not in set %d: %s", e.SetID, strings.Join(e.Outliers, ", ")))
+	}
+	if len(e.Missing) > 0 {
+		missing := make([]string, len(e.Missing))
+		for i, idx := range e.Missing {
+			missing[i] = strconv.Itoa(idx)
+		}
+		problems = append(problems, fmt.Sprintf("missing indexes of set %d: %s", e.SetID, strings.Join(missing, ", ")))
+	}
+	return "cab: cabinets do not make up one set: " + strings.Join(problems, "; ")
+}
+
+// Is reports whether target is ErrSetIDMismatch and some cabinets belong to another
+// set.
+func (e *SetMembersError) Is(target error) bool {
+	return target == ErrSetIDMismatch && len(e.Outliers) > 0
+}
+
+// openVolumes opens the named cabinets. If any cannot be opened, those already open are
+// closed.
+func openVolumes(names []string) ([]*ReadCloser, error) {
+	var closers []*ReadCloser
+	for _, name := range names {
+		rc, err := OpenReader(name)
+		if err != nil {
+			closeVolumes(closers)
+			return nil, err
+		}
+		closers = append(closers, rc)
+	}
+	return closers, nil
+}
+
+func closeVolumes(closers []*ReadCloser) {
+	for _, c := range closers {
+		c.Close()
+	}
+}
+
+// newOpenedSet returns a SetReader over closers, the cabinets opened from names,
+// which it closes if they do not make up a set.
+func newOpenedSet(names []string, closers []*ReadCloser) (*SetReader, error) {
+	volumes := make([]*Reader, len(closers))
+	for i, c := range closers {
+		volumes[i] = &c.Reader
+	}
+
+	s, err := NewSetReader(volumes...)
+	if err != nil {
+		closeVolumes(closers)
+		var verr *VolumeError
+		if errors.As(err, &verr) {
+			err = fmt.Errorf("cab: %s: %w", names[verr.Volume], verr.Err)
+		}
+		return nil, err
+	}
+
+	s.closers = closers
+	return s, nil
+}
+
+// NewSetReader returns a SetReader over volumes, which make up a set. The volumes may
+// be given in any order, but must all have the same SetID, or the error matches
+// ErrSetIDMismatch, and each a different SetIndex, or the error matches
+// ErrDuplicateSetIndex. Such errors are a *VolumeError giving the position in volumes
+// of the first that does not fit. The set is taken to be the one most of the volumes
+// belong to, or the first of those tied for most, so a stray volume is blamed
+// wherever it is given.
+//
+// Where a folder continues from one volume into the next, as reported by
+// Folder.ContinuesToNext and Folder.ContinuesFromPrev, the two parts are joined, so
+// the files they hold can be read from either volume's Reader.
+func NewSetReader(volumes ...*Reader) (*SetReader, error) {
+	if len(volumes) == 0 {
+		return nil, errors.New("cab: a set requires at least one cabinet")
+	}
+	if err := checkSetMembers(volumes); err != nil {
+		return nil, err
+	}
+
+	s := &SetReader{volumes: make([]*Reader, len(volumes))}
+	copy(s.volumes, volumes)
+	sort.SliceStable(s.volumes, func(i, j int) bool {
+		return s.volumes[i].setIdx < s.volumes[j].setIdx
+	})
+
+	for i := 1; i < len(s.volumes); i++ {
+		if err := linkVolumes(s.volumes[i-1], s.volumes[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// checkSetMembers reports whether volumes could make up one set, returning a
+// *VolumeError for the first that has a different set ID from the majority, as chosen
+// by majoritySetID, or the same index as one before it.
+func checkSetMembers(volumes []*Reader) error {
+	setID := majoritySetID(volumes)
+	seen := make(map[uint16]int, len(volumes))
+	for i, v := range volumes {
+		if v.setID != setID {
+			return &VolumeError{Volume: i, Err: fmt.Errorf("%w: set ID %d differs from %d", ErrSetIDMismatch, v.setID, setID)}
+		}
+		if j, ok := seen[v.setIdx]; ok {
+			return &VolumeError{Volume: i, Err: fmt.Errorf("%w: index %d is also that of volume %d", ErrDuplicateSetIndex, v.setIdx, j)}
+		}
+		seen[v.setIdx] = i
+	}
+	return nil
+}
+
+// majoritySetID returns the set ID most of volumes have. Of IDs shared by equally many
+// volumes, the one that appears first is chosen.
+func majoritySetID(volumes []*Reader) uint16 {
+	counts := make(map[uint16]int, len(volumes))
+	setID := volumes[0].setID
+	for _, v := range volumes {
+		counts[v.setID]++
+		if counts[v.setID] > counts[setID] {
+			setID = v.setID
+		}
+	}
+	return setID
+}
+
+// linkVolumes joins the parts of a folder continued from prev into v, if v follows
+// prev in their set.
+func linkVolumes(prev, v *Reader) error {
+	if v.setIdx != prev.setIdx+1 || len(prev.Folders) == 0 || len(v.Folders) == 0 {
+		return nil
+	}
+
+	last, first := prev.Folders[len(prev.Folders)-1], v.Folders[0]
+	if !last.ContinuesToNext() || !first.ContinuesFromPrev() {
+		return nil
+	}
+	if last.compressionType != first.compressionType || last.compressionBits != first.compressionBits {
+		return newCorruptError("continued folder changes compression")
+	}
+	last.next, first.prev = first, last
+	return nil
+}
+
+// IterateSet calls fn for each file of the set that begins with c, in order, following
+// NextCab from cabinet to cabinet. Each following cabinet is opened by calling open
+// with the Ref naming it, so volumes may be fetched lazily, such as downloaded on
+// demand: a volume is only opened once the files of the one before it have been
+// visited, or before then if a file yet to be visited continues into it. A file split
+// across cabinets is visited once, from the cabinet where it begins, and can be read
+// in full from within fn. Files continued into c from a previous cabinet are skipped,
+// as they cannot be read without it.
+//
+// Iteration stops after the cabinet whose NextCab is nil. It also stops, returning the
+// error, when fn returns an error, when open fails, such as because the referenced
+// volume is unavailable, and when the cabinet open returns is not the next one of the
+// set. The Readers returned by open belong to the caller, who must keep them usable
+// until IterateSet returns.
+func (c *Reader) IterateSet(open func(ref *Ref) (*Reader, error), fn func(file *File) error) error {
+	if err := c.checkFiles(); err != nil {
+		return err
+	}
+
+	openNext := func(v *Reader) (*Reader, error) {
+		next, err := open(v.NextCab)
+		if err != nil {
+			return nil, fmt.Errorf("cab: opening next cabinet %q: %w", v.NextCab.Name, err)
+		}
+		if err := next.checkFiles(); err != nil {
+			return nil, err
+		}
+		if next.setID != v.setID || int(next.setIdx) != int(v.setIdx)+1 {
+			return nil, fmt.Errorf("cab: %q is not the next cabinet of the set", v.NextCab.Name)
+		}
+		if err := linkVolumes(v, next); err != nil {
+			return nil, err
+		}
+		return next, nil
+	}
+
+	// the volumes opened but not yet visited, and the last volume opened
+	var ahead []*Reader
+	last := c
+
+	for v := c; v != nil; {
+		// open the volumes that the last folder of v continues through, so that its
+		// files can be read
+		for last.NextCab != nil && len(last.Folders) > 0 && last.Folders[len(last.Folders)-1].ContinuesToNext() &&
+			(last == v || len(last.Folders) == 1 && last.Folders[0].ContinuesFromPrev()) {
+			next, err := openNext(last)
+			if err != nil {
+				return err
+			}
+			ahead = append(ahead, next)
+			last = next
+		}
+
+		for _, file := range v.File {
+			if file.continuedFromPrev() {
+				continue
+			}
+			if err := fn(file); err != nil {
+				return err
+			}
+		}
+
+		if len(ahead) == 0 && v.NextCab != nil {
+			next, err := openNext(v)
+			if err != nil {
+				return err
+			}
+			ahead = append(ahead, next)
+			last = next
+		}
+
+		v = nil
+		if len(ahead) > 0 {
+			v, ahead = ahead[0], ahead[1:]
+		}
+	}
+
+	return nil
+}
+
+// Close closes the cabinets opened by OpenSet.
+func (s *SetReader) Close() error {
+	var err error
+	for _, c := range s.closers {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// Volumes returns the cabinets making up the set, ordered by their index in the set.
+func (s *SetReader) Volumes() []*Reader {
+	volumes := make([]*Reader, len(s.volumes))
+	copy(volumes, s.volumes)
+	return volumes
+}
+
+// Volume returns the cabinet with the given index in the set.
+func (s *SetReader) Volume(idx uint16) (*Reader, bool) {
+	for _, v := range s.volumes {
+		if v.setIdx == idx {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Files returns every file in the set, in volume order. A file split across cabinets
+// is listed by each cabinet it spans, but is returned once, as the entry of the volume
+// where it begins, so long as that volume is part of the set. The entries of a split
+// file all record its full size.
+func (s *SetReader) Files() []*File {
+	var files []*File
+	for i, v := range s.volumes {
+		// the previous volume lists the files continued from it
+		hasPrev := i > 0 && s.volumes[i-1].setIdx == v.setIdx-1
+		for _, folder := range v.Folders {
+			for _, file := range folder.Files {
+				if hasPrev && file.continuedFromPrev() {
+					continue
+				}
+				files = append(files, file)
+			}
+		}
+	}
+	return files
+}
+
+// OpenFile returns a ReadCloser for the contents of the named file, as listed by
+// Files. Names are compared as by Reader.FileByName. A file split across cabinets is
+// read as one stream, continuing through each volume it spans. If no file has the
+// name, the error wraps fs.ErrNotExist.
+func (s *SetReader) OpenFile(name string) (io.ReadCloser, error) {
+	for _, file := range s.Files() {
+		if equalNames(file.DecodeName(), name) {
+			return file.Open()
+		}
+	}
+	return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+}
+
+// TotalUncompressedSize returns the sum of the sizes of the files in the set, which is
+// the disk space needed to extract it. A file split across cabinets is counted once,
+// as it is listed by Files.
+func (s *SetReader) TotalUncompressedSize() int64 {
+	var total int64
+	for _, file := range s.Files() {
+		total += file.Size()
+	}
+	return total
+}
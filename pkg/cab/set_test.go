@@ -0,0 +1,640 @@
+package cab_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+// buildVolume assembles an uncompressed cabinet that is volume idx of the set setID.
+func buildVolume(setID, idx uint16, files ...testFile) []byte {
+	data := buildCab(files)
+	binary.LittleEndian.PutUint16(data[32:], setID)
+	binary.LittleEndian.PutUint16(data[34:], idx)
+	return data
+}
+
+// linkVolume marks a cabinet built by buildCab as having a previous and/or next
+// cabinet in its set, inserting names for them, with empty disk names, after the
+// header.
+func linkVolume(data []byte, prev, next bool) []byte {
+	le := binary.LittleEndian
+
+	var flags uint16
+	var refs []byte
+	if prev {
+		flags |= 0x1
+		refs = append(refs, "prev.cab\x00\x00"...)
+	}
+	if next {
+		flags |= 0x2
+		refs = append(refs, "next.cab\x00\x00"...)
+	}
+
+	linked := append(append(append([]byte(nil), data[:36]...), refs...), data[36:]...)
+	le.PutUint16(linked[30:], flags)
+	le.PutUint32(linked[8:], le.Uint32(linked[8:])+uint32(len(refs)))
+	le.PutUint32(linked[16:], le.Uint32(linked[16:])+uint32(len(refs)))
+	for i := 0; i < int(le.Uint16(linked[26:])); i++ {
+		off := 36 + len(refs) + 8*i
+		le.PutUint32(linked[off:], le.Uint32(linked[off:])+uint32(len(refs)))
+	}
+	return linked
+}
+
+func TestSetReaderVolumes(t *testing.T) {
+	var volumes []*cab.Reader
+	for _, idx := range []uint16{2, 0, 1} {
+		data := buildVolume(7, idx, testFile{name: "a.txt", data: []byte{byte(idx)}})
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		volumes = append(volumes, r)
+	}
+
+	s, err := cab.NewSetReader(volumes...)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	actual := s.Volumes()
+	expected := []*cab.Reader{volumes[1], volumes[2], volumes[0]}
+	if len(actual) != len(expected) {
+		t.Fatalf("expected %d volume(s), but got %d", len(expected), len(actual))
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("expected volume %d to be in position %d", i, i)
+		}
+	}
+
+	for i, v := range expected {
+		r, ok := s.Volume(uint16(i))
+		if !ok || r != v {
+			t.Fatalf("expected to find volume %d", i)
+		}
+	}
+
+	if _, ok := s.Volume(3); ok {
+		t.Fatalf("expected to not find volume 3")
+	}
+}
+
+func TestOpenSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	var names []string
+	for _, idx := range []uint16{1, 0} {
+		name := filepath.Join(dir, string(rune('a'+idx))+".cab")
+		if err := ioutil.WriteFile(name, buildVolume(7, idx, testFile{name: "a.txt"}), 0644); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		names = append(names, name)
+	}
+
+	s, err := cab.OpenSet(names...)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer s.Close()
+
+	if len(s.Volumes()) != 2 {
+		t.Fatalf("expected 2 volumes, but got %d", len(s.Volumes()))
+	}
+
+	if _, err := cab.OpenSet(filepath.Join(dir, "missing.cab")); err == nil {
+		t.Fatalf("expected an error, but got none")
+	}
+}
+
+func TestSetMembership(t *testing.T) {
+	testCases := []struct {
+		name     string
+		volumes  [][2]uint16 // set ID and index of each volume
+		expected error
+		culprit  string
+	}{
+		{name: "mixed sets", volumes: [][2]uint16{{7, 0}, {7, 1}, {8, 0}}, expected: cab.ErrSetIDMismatch, culprit: "c.cab"},
+		{name: "mixed sets first", volumes: [][2]uint16{{8, 1}, {7, 0}, {7, 1}}, expected: cab.ErrSetIDMismatch, culprit: "a.cab"},
+		{name: "mixed sets tied", volumes: [][2]uint16{{8, 1}, {7, 0}}, expected: cab.ErrSetIDMismatch, culprit: "b.cab"},
+		{name: "duplicate index", volumes: [][2]uint16{{7, 0}, {7, 1}, {7, 1}}, expected: cab.ErrDuplicateSetIndex, culprit: "c.cab"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cab")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			var names []string
+			var volumes []*cab.Reader
+			for i, v := range tc.volumes {
+				data := buildVolume(v[0], v[1], testFile{name: "a.txt", data: []byte("a")})
+				name := filepath.Join(dir, string(rune('a'+i))+".cab")
+				if err := ioutil.WriteFile(name, data, 0644); err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				names = append(names, name)
+
+				r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				volumes = append(volumes, r)
+			}
+
+			_, err = cab.NewSetReader(volumes...)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected %v, but got %v", tc.expected, err)
+			}
+			var verr *cab.VolumeError
+			if !errors.As(err, &verr) || verr.Volume != int(tc.culprit[0]-'a') {
+				t.Fatalf("expected a VolumeError for %s, but got %v", tc.culprit, err)
+			}
+
+			_, err = cab.OpenSet(names...)
+			if !errors.Is(err, tc.expected) {
+				t.Fatalf("expected %v, but got %v", tc.expected, err)
+			}
+			if !strings.Contains(err.Error(), tc.culprit) {
+				t.Fatalf("expected the error to name %s, but got %v", tc.culprit, err)
+			}
+		})
+	}
+}
+
+func TestOpenSetDir(t *testing.T) {
+	testCases := []struct {
+		name             string
+		volumes          map[string][2]uint16 // set ID and index of each cabinet
+		expectedOutliers []string
+		expectedMissing  []int
+	}{
+		{
+			name:    "complete",
+			volumes: map[string][2]uint16{"disk1.cab": {7, 0}, "disk2.CAB": {7, 1}},
+		},
+		{
+			name:             "unrelated",
+			volumes:          map[string][2]uint16{"disk1.cab": {7, 0}, "disk2.cab": {7, 1}, "other.cab": {9, 0}},
+			expectedOutliers: []string{"other.cab"},
+		},
+		{
+			name:             "unrelated first",
+			volumes:          map[string][2]uint16{"a.cab": {9, 3}, "disk1.cab": {7, 0}, "disk2.cab": {7, 1}},
+			expectedOutliers: []string{"a.cab"},
+		},
+		{
+			name:            "missing",
+			volumes:         map[string][2]uint16{"disk1.cab": {7, 0}, "disk3.cab": {7, 2}},
+			expectedMissing: []int{1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cab")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer os.RemoveAll(dir)
+
+			for name, v := range tc.volumes {
+				data := buildVolume(v[0], v[1], testFile{name: name + ".txt", data: []byte(name)})
+				if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, "readme.txt"), []byte("not a cabinet"), 0644); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			s, err := cab.OpenSetDir(dir)
+			if tc.expectedOutliers == nil && tc.expectedMissing == nil {
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				defer s.Close()
+				if len(s.Volumes()) != len(tc.volumes) {
+					t.Fatalf("expected %d volumes, but got %d", len(tc.volumes), len(s.Volumes()))
+				}
+				return
+			}
+
+			var merr *cab.SetMembersError
+			if !errors.As(err, &merr) {
+				t.Fatalf("expected a SetMembersError, but got %v", err)
+			}
+			if merr.SetID != 7 {
+				t.Fatalf("expected set 7, but got %d", merr.SetID)
+			}
+			var outliers []string
+			for _, name := range merr.Outliers {
+				outliers = append(outliers, filepath.Base(name))
+			}
+			if !reflect.DeepEqual(outliers, tc.expectedOutliers) {
+				t.Fatalf("expected outliers %v, but got %v", tc.expectedOutliers, outliers)
+			}
+			if !reflect.DeepEqual(merr.Missing, tc.expectedMissing) {
+				t.Fatalf("expected missing %v, but got %v", tc.expectedMissing, merr.Missing)
+			}
+			if errors.Is(err, cab.ErrSetIDMismatch) != (tc.expectedOutliers != nil) {
+				t.Fatalf("expected ErrSetIDMismatch only with outliers, but got %v", err)
+			}
+		})
+	}
+
+	empty, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(empty)
+	if _, err := cab.OpenSetDir(empty); err == nil {
+		t.Fatalf("expected an error for a directory without cabinets, but got none")
+	}
+}
+
+func TestSetReaderTotalUncompressedSize(t *testing.T) {
+	// b.bin begins in the first volume and is finished in the second, so both list it
+	first := buildVolume(7, 0,
+		testFile{name: "a.txt", data: []byte("a")},
+		testFile{name: "b.bin", data: make([]byte, 10)},
+	)
+	binary.LittleEndian.PutUint16(first[74:], 0xfffe)
+	first = linkVolume(first, false, true)
+
+	second := buildVolume(7, 1,
+		testFile{name: "b.bin", data: make([]byte, 10)},
+		testFile{name: "c.txt", data: []byte("ccc")},
+	)
+	binary.LittleEndian.PutUint16(second[52:], 0xfffd)
+	second = linkVolume(second, true, false)
+
+	var volumes []*cab.Reader
+	for i, data := range [][]byte{first, second} {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if expected := []int64{11, 13}[i]; r.TotalUncompressedSize() != expected {
+			t.Fatalf("expected volume %d to total %d bytes, but got %d", i, expected, r.TotalUncompressedSize())
+		}
+		volumes = append(volumes, r)
+	}
+
+	s, err := cab.NewSetReader(volumes[1], volumes[0])
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if actual := s.TotalUncompressedSize(); actual != 14 {
+		t.Fatalf("expected the set to total 14 bytes, but got %d", actual)
+	}
+}
+
+func TestSetReaderFiles(t *testing.T) {
+	split := bytes.Repeat([]byte("split"), 100)
+
+	// split.bin begins in the first volume and ends in the second; both list it with
+	// its full size
+	first := buildVolume(7, 0,
+		testFile{name: "a.txt", data: []byte("a")},
+		testFile{name: "split.bin", data: split},
+	)
+	binary.LittleEndian.PutUint16(first[74:], 0xfffe)
+	first = linkVolume(first, false, true)
+
+	second := buildVolume(7, 1,
+		testFile{name: "split.bin", data: split},
+		testFile{name: "c.txt", data: []byte("c")},
+	)
+	binary.LittleEndian.PutUint16(second[52:], 0xfffd)
+	second = linkVolume(second, true, false)
+
+	var volumes []*cab.Reader
+	for _, data := range [][]byte{second, first} {
+		r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		volumes = append(volumes, r)
+	}
+
+	s, err := cab.NewSetReader(volumes...)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var names []string
+	for _, file := range s.Files() {
+		names = append(names, file.Name)
+		if file.Name == "split.bin" && file.Size() != int64(len(split)) {
+			t.Fatalf("expected split.bin to have %d byte(s), but got %d", len(split), file.Size())
+		}
+	}
+	if expected := []string{"a.txt", "split.bin", "c.txt"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, but got %v", expected, names)
+	}
+
+	// without the first volume, the second is the only one listing split.bin
+	s, err = cab.NewSetReader(volumes[0])
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if actual := len(s.Files()); actual != 2 {
+		t.Fatalf("expected 2 files, but got %d", actual)
+	}
+}
+
+func TestSetReaderSplitFolder(t *testing.T) {
+	var stream []byte
+	a := []byte("a file that fits in the first volume")
+	split := bytes.Repeat([]byte("0123456789"), 300)
+	c := []byte("a file that fits in the second volume")
+	stream = append(append(append(stream, a...), split...), c...)
+
+	blocks := [][]byte{stream[:1000], stream[1000:2000], stream[2000:]}
+
+	testCases := []struct {
+		name         string
+		typeCompress uint16
+		encode       func([][]byte) [][]byte
+	}{
+		{name: "stored", typeCompress: 0, encode: func(blocks [][]byte) [][]byte { return blocks }},
+		{name: "MSZIP", typeCompress: 1, encode: mszipEncode},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := tc.encode(blocks)
+			// the second block is split between the volumes
+			k := len(encoded[1]) / 2
+
+			first := buildRawCab(0x2, tc.typeCompress,
+				[]rawFile{
+					{name: "a.txt", size: uint32(len(a))},
+					{name: "split.bin", size: uint32(len(split)), offset: uint32(len(a)), folder: 0xfffe},
+				},
+				[]rawBlock{
+					{data: encoded[0], uncompressedSize: uint16(len(blocks[0]))},
+					{data: encoded[1][:k]},
+				},
+			)
+			second := buildRawCab(0x1, tc.typeCompress,
+				[]rawFile{
+					{name: "split.bin", size: uint32(len(split)), offset: uint32(len(a)), folder: 0xfffd},
+					{name: "c.txt", size: uint32(len(c)), offset: uint32(len(a) + len(split))},
+				},
+				[]rawBlock{
+					{data: encoded[1][k:], uncompressedSize: uint16(len(blocks[1]))},
+					{data: encoded[2], uncompressedSize: uint16(len(blocks[2]))},
+				},
+			)
+			binary.LittleEndian.PutUint16(second[34:], 1)
+
+			var volumes []*cab.Reader
+			for _, data := range [][]byte{first, second} {
+				r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				volumes = append(volumes, r)
+			}
+
+			if !volumes[0].Folders[0].ContinuesToNext() || volumes[0].Folders[0].ContinuesFromPrev() {
+				t.Fatalf("expected the first volume's folder to only continue to the next")
+			}
+			if !volumes[1].Folders[0].ContinuesFromPrev() || volumes[1].Folders[0].ContinuesToNext() {
+				t.Fatalf("expected the second volume's folder to only continue from the previous")
+			}
+
+			s, err := cab.NewSetReader(volumes...)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			expected := map[string][]byte{"a.txt": a, "split.bin": split, "c.txt": c}
+			files := s.Files()
+			if len(files) != len(expected) {
+				t.Fatalf("expected %d files, but got %d", len(expected), len(files))
+			}
+			for _, file := range files {
+				if b := readFile(t, file); !bytes.Equal(b, expected[file.Name]) {
+					t.Fatalf("unexpected content for %s", file.Name)
+				}
+
+				b, err := file.ReadRange(1, file.Size()-2)
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if content := expected[file.Name]; !bytes.Equal(b, content[1:len(content)-1]) {
+					t.Fatalf("unexpected range of %s", file.Name)
+				}
+			}
+
+			// the entry of the split file in the second volume reads the same data
+			if b := readFile(t, volumes[1].Folders[0].Files[0]); !bytes.Equal(b, split) {
+				t.Fatalf("unexpected content for the second volume's split.bin")
+			}
+
+			// the folder is read from its start in the first volume
+			rc, err := volumes[1].Folders[0].Open()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer rc.Close()
+			if b, err := ioutil.ReadAll(rc); err != nil || !bytes.Equal(b, stream) {
+				t.Fatalf("expected the whole folder to be read, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestSetReaderOpenFile(t *testing.T) {
+	content := make([]byte, 6000)
+	rand.New(rand.NewSource(1)).Read(content)
+	blocks := [][]byte{content[:2000], content[2000:4000], content[4000:]}
+
+	testCases := []struct {
+		name         string
+		typeCompress uint16
+		encode       func([][]byte) [][]byte
+	}{
+		{name: "stored", typeCompress: 0, encode: func(blocks [][]byte) [][]byte { return blocks }},
+		{name: "MSZIP", typeCompress: 1, encode: mszipEncode},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			encoded := tc.encode(blocks)
+			k1, k2 := len(encoded[1])/2, len(encoded[2])/3
+			file := func(continuation uint16) []rawFile {
+				return []rawFile{{name: `data\big.bin`, size: uint32(len(content)), folder: continuation}}
+			}
+
+			// the file's second and third blocks are each split between two volumes
+			parts := [][]byte{
+				buildRawCab(0x2, tc.typeCompress, file(0xfffe), []rawBlock{
+					{data: encoded[0], uncompressedSize: 2000},
+					{data: encoded[1][:k1]},
+				}),
+				buildRawCab(0x3, tc.typeCompress, file(0xffff), []rawBlock{
+					{data: encoded[1][k1:], uncompressedSize: 2000},
+					{data: encoded[2][:k2]},
+				}),
+				buildRawCab(0x1, tc.typeCompress, file(0xfffd), []rawBlock{
+					{data: encoded[2][k2:], uncompressedSize: 2000},
+				}),
+			}
+
+			// give the volumes out of order
+			var volumes []*cab.Reader
+			for _, idx := range []int{2, 0, 1} {
+				data := parts[idx]
+				binary.LittleEndian.PutUint16(data[34:], uint16(idx))
+				r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				volumes = append(volumes, r)
+			}
+
+			s, err := cab.NewSetReader(volumes...)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			rc, err := s.OpenFile("DATA/big.bin")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			defer rc.Close()
+
+			b, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if !bytes.Equal(b, content) {
+				t.Fatalf("expected the file's content to be read across all volumes")
+			}
+
+			if _, err := s.OpenFile("missing.bin"); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("expected %v, but got %v", fs.ErrNotExist, err)
+			}
+		})
+	}
+}
+
+func TestReaderIterateSet(t *testing.T) {
+	content := make([]byte, 6000)
+	rand.New(rand.NewSource(1)).Read(content)
+	big := func(continuation uint16) []rawFile {
+		return []rawFile{{name: "big.bin", size: uint32(len(content)), folder: continuation}}
+	}
+
+	// big.bin spans the first three volumes; the last holds a file of its own
+	parts := [][]byte{
+		buildRawCab(0x2, 0, big(0xfffe), []rawBlock{{data: content[:2000], uncompressedSize: 2000}}),
+		buildRawCab(0x3, 0, big(0xffff), []rawBlock{{data: content[2000:4000], uncompressedSize: 2000}}),
+		buildRawCab(0x3, 0, big(0xfffd), []rawBlock{{data: content[4000:], uncompressedSize: 2000}}),
+		linkVolume(buildCab([]testFile{{name: "last.txt", data: []byte("last")}}), true, false),
+	}
+
+	open := func(t *testing.T) []*cab.Reader {
+		var volumes []*cab.Reader
+		for idx, data := range parts {
+			data = append([]byte(nil), data...)
+			binary.LittleEndian.PutUint16(data[34:], uint16(idx))
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			volumes = append(volumes, r)
+		}
+		return volumes
+	}
+
+	t.Run("lazy", func(t *testing.T) {
+		volumes := open(t)
+
+		var events []string
+		opened := 0
+		opener := func(ref *cab.Ref) (*cab.Reader, error) {
+			opened++
+			events = append(events, fmt.Sprintf("open %d", opened))
+			return volumes[opened], nil
+		}
+
+		err := volumes[0].IterateSet(opener, func(file *cab.File) error {
+			events = append(events, file.Name)
+			if b := readFile(t, file); file.Name == "big.bin" && !bytes.Equal(b, content) {
+				t.Fatalf("expected big.bin to be read across volumes")
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+
+		expected := []string{"open 1", "open 2", "big.bin", "open 3", "last.txt"}
+		if !reflect.DeepEqual(events, expected) {
+			t.Fatalf("expected %v, but got %v", expected, events)
+		}
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		volumes := open(t)
+		unavailable := errors.New("unavailable")
+
+		// the second volume is available, but the third, which big.bin continues
+		// into, is not
+		var visited []string
+		opened := 0
+		err := volumes[0].IterateSet(func(ref *cab.Ref) (*cab.Reader, error) {
+			opened++
+			if opened == 1 {
+				return volumes[1], nil
+			}
+			return nil, unavailable
+		}, func(file *cab.File) error {
+			visited = append(visited, file.Name)
+			return nil
+		})
+		if !errors.Is(err, unavailable) {
+			t.Fatalf("expected %v, but got %v", unavailable, err)
+		}
+		if len(visited) != 0 {
+			t.Fatalf("expected no files to be visited, but got %v", visited)
+		}
+	})
+
+	t.Run("wrong volume", func(t *testing.T) {
+		volumes := open(t)
+		err := volumes[2].IterateSet(func(ref *cab.Ref) (*cab.Reader, error) {
+			return volumes[0], nil
+		}, func(file *cab.File) error {
+			return nil
+		})
+		if err == nil || !strings.Contains(err.Error(), "not the next cabinet") {
+			t.Fatalf("expected an error about the wrong cabinet, but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,187 @@
+package cab_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+)
+
+type testFile struct {
+	name string
+	data []byte
+}
+
+// buildCab assembles an uncompressed cabinet containing one folder per element of
+// folders.
+func buildCab(folders ...[]testFile) []byte {
+	return assembleCab(0, func(blocks [][]byte) [][]byte { return blocks }, folders)
+}
+
+// buildMSZIPCab assembles an MSZIP compressed cabinet containing one folder per
+// element of folders.
+func buildMSZIPCab(folders ...[]testFile) []byte {
+	return assembleCab(1, mszipEncode, folders)
+}
+
+// mszipEncode compresses each block as a "CK" signature followed by a deflate stream
+// using the previous block as its dictionary.
+func mszipEncode(blocks [][]byte) [][]byte {
+	var encoded [][]byte
+	var prev []byte
+	for _, block := range blocks {
+		var buf bytes.Buffer
+		buf.WriteString("CK")
+		fw, err := flate.NewWriterDict(&buf, flate.BestCompression, prev)
+		if err != nil {
+			panic(err)
+		}
+		fw.Write(block)
+		fw.Close()
+
+		encoded = append(encoded, buf.Bytes())
+		prev = block
+	}
+	return encoded
+}
+
+// assembleCab assembles a cabinet whose folders all use typeCompress, with encode
+// converting each folder's uncompressed blocks into the data stored in its CFDATA
+// entries.
+func assembleCab(typeCompress uint16, encode func(blocks [][]byte) [][]byte, folders [][]testFile) []byte {
+	const headerSize = 36
+	const folderSize = 8
+	const fileSize = 16
+
+	numFiles := 0
+	filesSize := 0
+	for _, files := range folders {
+		numFiles += len(files)
+		for _, f := range files {
+			filesSize += fileSize + len(f.name) + 1
+		}
+	}
+
+	coffFiles := headerSize + folderSize*len(folders)
+	dataOffset := coffFiles + filesSize
+
+	var header, folderTable, fileTable, data bytes.Buffer
+	le := binary.LittleEndian
+
+	for i, files := range folders {
+		var stream []byte
+		for _, f := range files {
+			binary.Write(&fileTable, le, uint32(len(f.data)))
+			binary.Write(&fileTable, le, uint32(len(stream)))
+			binary.Write(&fileTable, le, uint16(i))
+			binary.Write(&fileTable, le, uint16(0)) // date
+			binary.Write(&fileTable, le, uint16(0)) // time
+			binary.Write(&fileTable, le, uint16(0)) // attributes
+			fileTable.WriteString(f.name)
+			fileTable.WriteByte(0)
+			stream = append(stream, f.data...)
+		}
+
+		var blocks [][]byte
+		for len(stream) > 0 {
+			n := len(stream)
+			if n > 32768 {
+				n = 32768
+			}
+			blocks = append(blocks, stream[:n])
+			stream = stream[n:]
+		}
+
+		binary.Write(&folderTable, le, uint32(dataOffset+data.Len()))
+		binary.Write(&folderTable, le, uint16(len(blocks)))
+		binary.Write(&folderTable, le, typeCompress)
+
+		for j, encoded := range encode(blocks) {
+			binary.Write(&data, le, uint32(0)) // checksum
+			binary.Write(&data, le, uint16(len(encoded)))
+			binary.Write(&data, le, uint16(len(blocks[j])))
+			data.Write(encoded)
+		}
+	}
+
+	header.WriteString("MSCF")
+	binary.Write(&header, le, uint32(0))
+	binary.Write(&header, le, uint32(dataOffset+data.Len()))
+	binary.Write(&header, le, uint32(0))
+	binary.Write(&header, le, uint32(coffFiles))
+	binary.Write(&header, le, uint32(0))
+	header.Write([]byte{3, 1})
+	binary.Write(&header, le, uint16(len(folders)))
+	binary.Write(&header, le, uint16(numFiles))
+	binary.Write(&header, le, uint16(0)) // flags
+	binary.Write(&header, le, uint16(0)) // setID
+	binary.Write(&header, le, uint16(0)) // iCabinet
+
+	return bytes.Join([][]byte{header.Bytes(), folderTable.Bytes(), fileTable.Bytes(), data.Bytes()}, nil)
+}
+
+type rawFile struct {
+	name   string
+	size   uint32
+	offset uint32
+	folder uint16
+}
+
+type rawBlock struct {
+	data             []byte
+	uncompressedSize uint16
+}
+
+// buildRawCab assembles a cabinet with a single folder from file entries and data
+// blocks given exactly as they are to be stored. If flags links the cabinet to a
+// previous or next one, they are named prev.cab and next.cab, with empty disk names.
+func buildRawCab(flags, typeCompress uint16, files []rawFile, blocks []rawBlock) []byte {
+	le := binary.LittleEndian
+
+	var refs []byte
+	if flags&0x1 != 0 {
+		refs = append(refs, "prev.cab\x00\x00"...)
+	}
+	if flags&0x2 != 0 {
+		refs = append(refs, "next.cab\x00\x00"...)
+	}
+
+	var fileTable, data bytes.Buffer
+	for _, f := range files {
+		binary.Write(&fileTable, le, f.size)
+		binary.Write(&fileTable, le, f.offset)
+		binary.Write(&fileTable, le, f.folder)
+		binary.Write(&fileTable, le, uint32(0)) // date and time
+		binary.Write(&fileTable, le, uint16(0)) // attributes
+		fileTable.WriteString(f.name)
+		fileTable.WriteByte(0)
+	}
+	for _, b := range blocks {
+		binary.Write(&data, le, uint32(0)) // checksum
+		binary.Write(&data, le, uint16(len(b.data)))
+		binary.Write(&data, le, b.uncompressedSize)
+		data.Write(b.data)
+	}
+
+	coffFiles := 36 + len(refs) + 8
+	dataOffset := coffFiles + fileTable.Len()
+
+	var header bytes.Buffer
+	header.WriteString("MSCF")
+	binary.Write(&header, le, uint32(0))
+	binary.Write(&header, le, uint32(dataOffset+data.Len()))
+	binary.Write(&header, le, uint32(0))
+	binary.Write(&header, le, uint32(coffFiles))
+	binary.Write(&header, le, uint32(0))
+	header.Write([]byte{3, 1})
+	binary.Write(&header, le, uint16(1)) // folders
+	binary.Write(&header, le, uint16(len(files)))
+	binary.Write(&header, le, flags)
+	binary.Write(&header, le, uint16(0)) // setID
+	binary.Write(&header, le, uint16(0)) // iCabinet
+	header.Write(refs)
+	binary.Write(&header, le, uint32(dataOffset))
+	binary.Write(&header, le, uint16(len(blocks)))
+	binary.Write(&header, le, typeCompress)
+
+	return bytes.Join([][]byte{header.Bytes(), fileTable.Bytes(), data.Bytes()}, nil)
+}
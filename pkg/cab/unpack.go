@@ -0,0 +1,30 @@
+package cab
+
+import "fmt"
+
+// Unpack extracts every file in the cabinet named src into dstDir. It opens the
+// cabinet, verifies its checksums if WithVerifyChecksums is specified, and then calls
+// ExtractTo, so names that would escape dstDir are rejected. The remaining options
+// control the extraction, such as whether modification times are restored.
+//
+// The returned error identifies the stage that failed: opening, verifying or
+// extracting.
+func Unpack(src, dstDir string, opts ...ReaderOption) error {
+	r, err := OpenReader(src, opts...)
+	if err != nil {
+		return fmt.Errorf("cab: opening %s: %w", src, err)
+	}
+	defer r.Close()
+
+	if r.opts.verifyChecksums {
+		if err := r.VerifyChecksums(); err != nil {
+			return fmt.Errorf("cab: verifying %s: %w", src, err)
+		}
+	}
+
+	if err := r.ExtractTo(dstDir); err != nil {
+		return fmt.Errorf("cab: extracting %s: %w", src, err)
+	}
+
+	return nil
+}
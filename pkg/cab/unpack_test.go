@@ -0,0 +1,97 @@
+package cab_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestUnpack(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	if err := cab.Unpack("testdata/readme.cab", out, cab.WithVerifyChecksums(), cab.WithRestoreModTimes()); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	path := filepath.Join(out, "README.md")
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if string(b) != "# gocab\r\nCab archive library for go.\r\n" {
+		t.Fatalf("unexpected content %q", b)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if expected := time.Date(2019, 11, 21, 18, 44, 32, 0, time.UTC); !fi.ModTime().Equal(expected) {
+		t.Fatalf("expected modification time %v, but got %v", expected, fi.ModTime())
+	}
+}
+
+func TestUnpackErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	readme, err := ioutil.ReadFile("testdata/readme.cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	// a byte of the data block
+	readme[0x50] ^= 0xff
+	corrupt := filepath.Join(dir, "corrupt.cab")
+	if err := ioutil.WriteFile(corrupt, readme, 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	traversal := filepath.Join(dir, "traversal.cab")
+	if err := ioutil.WriteFile(traversal, buildCab([]testFile{{name: `..\evil.txt`, data: []byte("evil")}}), 0644); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		src      string
+		stage    string
+		expected error
+	}{
+		{name: "missing", src: filepath.Join(dir, "missing.cab"), stage: "cab: opening", expected: os.ErrNotExist},
+		{name: "checksum", src: corrupt, stage: "cab: verifying", expected: cab.ErrChecksum},
+		{name: "traversal", src: traversal, stage: "cab: extracting"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := cab.Unpack(tc.src, filepath.Join(dir, "out"), cab.WithVerifyChecksums())
+			if err == nil {
+				t.Fatalf("expected an error, but got none")
+			}
+			if !strings.HasPrefix(err.Error(), tc.stage) {
+				t.Fatalf("expected %q to start with %q", err, tc.stage)
+			}
+			if tc.expected != nil && !errors.Is(err, tc.expected) {
+				t.Fatalf("expected %v to match %v", err, tc.expected)
+			}
+		})
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "evil.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected evil.txt to not exist, but got %v", err)
+	}
+}
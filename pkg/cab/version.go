@@ -0,0 +1,85 @@
+package cab
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Version is a cabinet format version. Major and Minor are the bytes stored in the
+// header.
+type Version struct {
+	Major uint8
+	Minor uint8
+}
+
+// supportedVersion is the newest cabinet format version this package understands.
+var supportedVersion = Version{Major: 1, Minor: 3}
+
+// String returns the version as "major.minor", such as "1.3".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d", v.Major, v.Minor)
+}
+
+// Less reports whether v is older than other.
+func (v Version) Less(other Version) bool {
+	if v.Major != other.Major {
+		return v.Major < other.Major
+	}
+	return v.Minor < other.Minor
+}
+
+// AtLeast reports whether v is the same as or newer than other.
+func (v Version) AtLeast(other Version) bool {
+	return !v.Less(other)
+}
+
+// ErrUnsupportedVersion is matched by errors reporting a cabinet whose format version
+// is newer than this package understands.
+var ErrUnsupportedVersion = errors.New("cab: unsupported cabinet version")
+
+// UnsupportedVersionError reports a cabinet whose format version is newer than this
+// package understands. Such a cabinet may lay out its structures differently, so
+// anything parsed from it may be unreliable.
+type UnsupportedVersionError struct {
+	Version Version
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("cab: unsupported cabinet version %v (newest supported is %v)", e.Version, supportedVersion)
+}
+
+// Is reports whether target is ErrUnsupportedVersion.
+func (e *UnsupportedVersionError) Is(target error) bool {
+	return target == ErrUnsupportedVersion
+}
+
+// Version returns the format version recorded in the cabinet's header.
+func (c *Reader) Version() Version {
+	return Version{Major: c.majorVersion, Minor: c.minorVersion}
+}
+
+// Warnings returns the problems that were tolerated while opening the cabinet because
+// of a lenient option, such as WithLenientVersion, followed by the folders skipped by
+// extraction because of WithSkipUndecodableFolders. Each skipped folder is listed once,
+// however many times the cabinet is extracted. It is empty when the cabinet was read
+// without concessions.
+func (c *Reader) Warnings() []error {
+	return c.warnings
+}
+
+// checkVersion rejects versions newer than supported, or records them as a warning
+// when opening leniently.
+func (c *Reader) checkVersion() error {
+	v := c.Version()
+	if !supportedVersion.Less(v) {
+		return nil
+	}
+
+	err := &UnsupportedVersionError{Version: v}
+	if !c.opts.lenientVersion {
+		return err
+	}
+
+	c.warnings = append(c.warnings, err)
+	return nil
+}
@@ -0,0 +1,96 @@
+package cab_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderVersion(t *testing.T) {
+	testCases := []struct {
+		name         string
+		major, minor uint8
+		supported    bool
+	}{
+		{name: "1.3", major: 1, minor: 3, supported: true},
+		{name: "1.1", major: 1, minor: 1, supported: true},
+		{name: "1.4", major: 1, minor: 4},
+		{name: "2.0", major: 2, minor: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data := buildCab([]testFile{{name: "a.txt", data: []byte("a")}})
+			data[24] = tc.minor
+			data[25] = tc.major
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if tc.supported {
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if v := r.Version(); v != (cab.Version{Major: tc.major, Minor: tc.minor}) {
+					t.Fatalf("expected version %s, but got %v", tc.name, v)
+				}
+				if len(r.Warnings()) != 0 {
+					t.Fatalf("expected no warnings, but got %v", r.Warnings())
+				}
+				return
+			}
+
+			if !errors.Is(err, cab.ErrUnsupportedVersion) {
+				t.Fatalf("expected %v to match %v", err, cab.ErrUnsupportedVersion)
+			}
+			var verr *cab.UnsupportedVersionError
+			if !errors.As(err, &verr) || verr.Version.String() != tc.name {
+				t.Fatalf("expected an UnsupportedVersionError for %s, but got %v", tc.name, err)
+			}
+
+			r, err = cab.NewReader(bytes.NewReader(data), int64(len(data)), cab.WithLenientVersion())
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if v := r.Version(); v != (cab.Version{Major: tc.major, Minor: tc.minor}) {
+				t.Fatalf("expected version %s, but got %v", tc.name, v)
+			}
+			warnings := r.Warnings()
+			if len(warnings) != 1 || !errors.Is(warnings[0], cab.ErrUnsupportedVersion) {
+				t.Fatalf("expected one unsupported version warning, but got %v", warnings)
+			}
+			if len(r.Folders) != 1 || len(r.Folders[0].Files) != 1 {
+				t.Fatalf("expected the cabinet to be parsed, but got %d folder(s)", len(r.Folders))
+			}
+		})
+	}
+}
+
+func TestVersionCompare(t *testing.T) {
+	v13 := cab.Version{Major: 1, Minor: 3}
+
+	testCases := []struct {
+		v       cab.Version
+		less    bool
+		atLeast bool
+	}{
+		{v: cab.Version{Major: 1, Minor: 2}, less: true},
+		{v: cab.Version{Major: 0, Minor: 9}, less: true},
+		{v: cab.Version{Major: 1, Minor: 3}, atLeast: true},
+		{v: cab.Version{Major: 1, Minor: 4}, atLeast: true},
+		{v: cab.Version{Major: 2, Minor: 0}, atLeast: true},
+	}
+
+	for _, tc := range testCases {
+		if tc.v.Less(v13) != tc.less {
+			t.Fatalf("expected %v.Less(%v) to be %v", tc.v, v13, tc.less)
+		}
+		if tc.v.AtLeast(v13) != tc.atLeast {
+			t.Fatalf("expected %v.AtLeast(%v) to be %v", tc.v, v13, tc.atLeast)
+		}
+	}
+
+	if s := v13.String(); s != "1.3" {
+		t.Fatalf("expected %q, but got %q", "1.3", s)
+	}
+}
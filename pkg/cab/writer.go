@@ -0,0 +1,452 @@
+package cab
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNameLen is the largest file name, including its terminator, a cabinet may hold.
+const maxNameLen = 256
+
+// maxFolderSize is the largest number of uncompressed bytes a folder may hold.
+const maxFolderSize = 0x7fff8000
+
+// Writer implements a cab file writer. All files are stored in a single folder,
+// compressed as specified by WithCompression. The data is buffered until Close, when
+// the complete cabinet is written to the underlying writer.
+type Writer struct {
+	w      io.Writer
+	opts   writerOptions
+	err    error
+	files  []*writerFile
+	data   bytes.Buffer // the encoded CFDATA entries
+	block  []byte       // uncompressed data not yet encoded into a CFDATA entry
+	window []byte       // MSZIP history preceding block
+	comp   Compressor   // the Compressor of a registered compression type
+	blocks int
+	size   int64 // uncompressed size of the folder
+	closed bool
+
+	prev, next *Ref   // the neighbouring cabinets of a set, if any
+	setID      uint16 // the identifier shared by the cabinets of the set
+	setIdx     uint16 // the position of the cabinet in its set
+}
+
+type writerFile struct {
+	name               string
+	uncompressedSize   uint32
+	uncompressedOffset uint32
+	date               uint16
+	time               uint16
+	attributes         uint16
+}
+
+// NewWriter returns a new Writer writing a cab file to w.
+func NewWriter(w io.Writer, opts ...WriterOption) *Writer {
+	cw := &Writer{w: w, opts: newWriterOptions(opts)}
+	switch t, bits := cw.opts.compression, cw.opts.windowBits; {
+	case bits < 0 || bits > 0x1f:
+		cw.err = fmt.Errorf("cab: invalid window bits %d", bits)
+	case t == CompressionNone || t == CompressionMSZIP:
+		if bits != 0 {
+			cw.err = &UnsupportedCompressionError{CompressionType: t, WindowBits: bits}
+		}
+	default:
+		cw.comp, cw.err = newCompressor(t, bits)
+	}
+	if cw.err == nil && (cw.opts.blockSize < 1 || cw.opts.blockSize > maxBlockSize) {
+		cw.err = fmt.Errorf("cab: invalid block size %d", cw.opts.blockSize)
+	}
+	return cw
+}
+
+// SetPrevCabinet records that the cabinet follows the one with the given name, on the
+// disk with the given name, in a set, as reported by Reader.PrevCab. The name must not
+// be empty; the disk's name may be. It must be called before Close.
+func (w *Writer) SetPrevCabinet(name, disk string) error {
+	ref, err := w.newRef(name, disk)
+	if err != nil {
+		return err
+	}
+	w.prev = ref
+	return nil
+}
+
+// SetNextCabinet records that the cabinet is followed by the one with the given name,
+// on the disk with the given name, in a set, as reported by Reader.NextCab. The name
+// must not be empty; the disk's name may be. It must be called before Close.
+func (w *Writer) SetNextCabinet(name, disk string) error {
+	ref, err := w.newRef(name, disk)
+	if err != nil {
+		return err
+	}
+	w.next = ref
+	return nil
+}
+
+// SetSetID records the identifier shared by the cabinets of a set, as reported by
+// Reader.SetID. Every cabinet of a set must be given the same one. It must be called
+// before Close; by default the identifier is 0.
+func (w *Writer) SetSetID(id uint16) error {
+	if w.closed {
+		return errors.New("cab: writer is closed")
+	}
+	w.setID = id
+	return nil
+}
+
+// SetSetIndex records the position of the cabinet in its set, starting at 0, as
+// reported by Reader.SetIndex. NewSetReader and IterateSet rely on it to order the
+// cabinets, so each cabinet of a set must be given a different one, one more than that
+// of the cabinet it follows. It must be called before Close; by default the position
+// is 0.
+func (w *Writer) SetSetIndex(index int) error {
+	if w.closed {
+		return errors.New("cab: writer is closed")
+	}
+	if index < 0 || index > 0xffff {
+		return fmt.Errorf("cab: invalid set index %d", index)
+	}
+	w.setIdx = uint16(index)
+	return nil
+}
+
+func (w *Writer) newRef(name, disk string) (*Ref, error) {
+	if w.closed {
+		return nil, errors.New("cab: writer is closed")
+	}
+	if name == "" {
+		return nil, errors.New("cab: cabinet name cannot be empty")
+	}
+	for _, s := range []string{name, disk} {
+		if len(s) >= maxNameLen || strings.IndexByte(s, 0) >= 0 {
+			return nil, fmt.Errorf("cab: invalid cabinet reference %q", s)
+		}
+	}
+	return &Ref{Name: name, Disk: disk}, nil
+}
+
+// WriteFiles writes a cabinet holding the given files, keyed by name, to w. The files
+// are added with Create in order of name, so the same files always produce the same
+// cabinet. It does not close w.
+func WriteFiles(w io.Writer, files map[string][]byte, opts ...WriterOption) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cw := NewWriter(w, opts...)
+	for _, name := range names {
+		fw, err := cw.Create(name)
+		if err != nil {
+			return fmt.Errorf("cab: writing %q: %w", name, err)
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return fmt.Errorf("cab: writing %q: %w", name, err)
+		}
+	}
+
+	return cw.Close()
+}
+
+// Create adds a file to the cabinet using the provided name and returns a Writer to
+// which the file contents should be written. The name is a relative path that should
+// use backslashes as separators. The file's contents must be written before the next
+// call to Create, AddDir or Close. They may be written in any number of calls, and the
+// file's size need not be known in advance: it is the total written, which is
+// recorded when the cabinet's headers are written at Close.
+func (w *Writer) Create(name string) (io.Writer, error) {
+	f, err := w.addFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &fileWriter{w: w, f: f}, nil
+}
+
+// AddDir adds an empty directory to the cabinet. The cabinet format has no directory
+// entries, so the directory is stored as a zero-length file whose name ends in a
+// backslash. This convention is not part of the format, but it is common and is
+// understood by this package's Reader, which reports such files with File.IsDir and
+// recreates them as directories when extracting.
+func (w *Writer) AddDir(name string) error {
+	name = strings.TrimRight(name, `\/`)
+	if name == "" {
+		return errors.New("cab: invalid directory name")
+	}
+
+	_, err := w.addFile(name + `\`)
+	return err
+}
+
+func (w *Writer) addFile(name string) (*writerFile, error) {
+	if w.err != nil {
+		return nil, w.err
+	}
+	if w.closed {
+		return nil, errors.New("cab: writer is closed")
+	}
+	if name == "" || len(name) >= maxNameLen || strings.IndexByte(name, 0) >= 0 {
+		return nil, errors.New("cab: invalid file name")
+	}
+	if len(w.files) == 0xffff {
+		return nil, errors.New("cab: too many files")
+	}
+
+	f := &writerFile{
+		name:               name,
+		uncompressedOffset: uint32(w.size),
+	}
+	if !isASCII(name) {
+		if !utf8.ValidString(name) {
+			return nil, errors.New("cab: invalid file name")
+		}
+		f.attributes |= uint16(AttrNameIsUTF)
+	}
+
+	w.files = append(w.files, f)
+	return f, nil
+}
+
+// FlushBlock ends the current data block so that data written afterwards starts a
+// new one. Calling it between files aligns the next file to a block boundary, which
+// lets readers reach it without decoding the blocks before it where the compression
+// allows. Each block has its own header and, when compressed, is encoded with less
+// context, so flushing often makes the cabinet larger. FlushBlock does nothing if the
+// current block is empty.
+func (w *Writer) FlushBlock() error {
+	if w.closed {
+		return errors.New("cab: writer is closed")
+	}
+
+	if len(w.block) > 0 {
+		return w.flushBlock()
+	}
+	return nil
+}
+
+// Close finishes writing the cab file. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return errors.New("cab: writer is closed")
+	}
+	w.closed = true
+
+	if len(w.block) > 0 {
+		if err := w.flushBlock(); err != nil {
+			return err
+		}
+	}
+
+	const headerSize = 36
+	const folderSize = 8
+	const fileSize = 16
+
+	numFolders := 0
+	if len(w.files) > 0 {
+		numFolders = 1
+	}
+
+	filesSize := 0
+	for _, f := range w.files {
+		filesSize += fileSize + len(f.name) + 1
+	}
+
+	var flags uint16
+	var refs []byte
+	if w.prev != nil {
+		flags |= flagPrevCabinet
+		refs = append(append(append(append(refs, w.prev.Name...), 0), w.prev.Disk...), 0)
+	}
+	if w.next != nil {
+		flags |= flagNextCabinet
+		refs = append(append(append(append(refs, w.next.Name...), 0), w.next.Disk...), 0)
+	}
+
+	coffFiles := headerSize + len(refs) + folderSize*numFolders
+	dataOffset := coffFiles + filesSize
+	cabinetSize := int64(dataOffset) + int64(w.data.Len())
+	if cabinetSize > 0xffffffff {
+		return errors.New("cab: cabinet too large")
+	}
+
+	b := make([]byte, 0, dataOffset)
+
+	b = append(b, Magic...)
+	b = appendUint32(b, 0)
+	b = appendUint32(b, uint32(cabinetSize))
+	b = appendUint32(b, 0)
+	b = appendUint32(b, uint32(coffFiles))
+	b = appendUint32(b, 0)
+	b = append(b, 3, 1) // version 1.3
+	b = appendUint16(b, uint16(numFolders))
+	b = appendUint16(b, uint16(len(w.files)))
+	b = appendUint16(b, flags)
+	b = appendUint16(b, w.setID)
+	b = appendUint16(b, w.setIdx)
+	b = append(b, refs...)
+
+	if numFolders > 0 {
+		b = appendUint32(b, uint32(dataOffset))
+		b = appendUint16(b, uint16(w.blocks))
+		b = appendUint16(b, uint16(w.opts.compression)|uint16(w.opts.windowBits)<<8)
+	}
+
+	for _, f := range w.files {
+		b = appendUint32(b, f.uncompressedSize)
+		b = appendUint32(b, f.uncompressedOffset)
+		b = appendUint16(b, 0) // iFolder
+		b = appendUint16(b, f.date)
+		b = appendUint16(b, f.time)
+		b = appendUint16(b, f.attributes)
+		b = append(b, f.name...)
+		b = append(b, 0)
+	}
+
+	if _, err := w.w.Write(b); err != nil {
+		return err
+	}
+
+	_, err := w.data.WriteTo(w.w)
+	return err
+}
+
+func (w *Writer) write(f *writerFile, p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("cab: writer is closed")
+	}
+	if f != w.files[len(w.files)-1] {
+		return 0, errors.New("cab: write to a file after a subsequent file was added")
+	}
+	if uint64(f.uncompressedSize)+uint64(len(p)) > 0xffffffff {
+		return 0, errors.New("cab: file too large")
+	}
+	if w.size+int64(len(p)) > maxFolderSize {
+		return 0, errors.New("cab: folder too large")
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		c := w.opts.blockSize - len(w.block)
+		if c > len(p) {
+			c = len(p)
+		}
+		w.block = append(w.block, p[:c]...)
+		p = p[c:]
+
+		if len(w.block) == w.opts.blockSize {
+			if err := w.flushBlock(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	f.uncompressedSize += uint32(n)
+	w.size += int64(n)
+	return n, nil
+}
+
+// flushBlock encodes the pending data as a CFDATA entry.
+func (w *Writer) flushBlock() error {
+	encoded := w.block
+	switch {
+	case w.opts.compression == CompressionMSZIP:
+		var err error
+		if encoded, err = w.mszipEncode(w.block); err != nil {
+			return err
+		}
+	case w.comp != nil:
+		var err error
+		if encoded, err = w.comp.Compress(nil, w.block); err != nil {
+			return err
+		}
+		if len(encoded) > 0xffff {
+			return errors.New("cab: compressed block too large")
+		}
+	}
+
+	if err := w.writeBlock(encoded, len(w.block)); err != nil {
+		return err
+	}
+	w.block = w.block[:0]
+	return nil
+}
+
+// writeBlock appends a CFDATA entry holding the encoded data of a block that
+// decompresses to uncompressedSize bytes, along with its checksum.
+func (w *Writer) writeBlock(encoded []byte, uncompressedSize int) error {
+	if w.blocks == 0xffff {
+		return errors.New("cab: too many data blocks")
+	}
+
+	var hdr [dataHeaderSize]byte
+	binary.LittleEndian.PutUint16(hdr[4:6], uint16(len(encoded)))
+	binary.LittleEndian.PutUint16(hdr[6:8], uint16(uncompressedSize))
+	binary.LittleEndian.PutUint32(hdr[0:4], blockChecksum(uint16(len(encoded)), uint16(uncompressedSize), encoded))
+	w.data.Write(hdr[:])
+	w.data.Write(encoded)
+
+	w.blocks++
+	return nil
+}
+
+// mszipEncode compresses block as a "CK" signature followed by a deflate stream that
+// uses the preceding 32KB of the folder as its dictionary.
+func (w *Writer) mszipEncode(block []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("CK")
+	fw, err := flate.NewWriterDict(&buf, flate.DefaultCompression, w.window)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fw.Write(block); err != nil {
+		return nil, err
+	}
+	if err := fw.Close(); err != nil {
+		return nil, err
+	}
+
+	w.window = append(w.window, block...)
+	if len(w.window) > mszipWindowSize {
+		w.window = append(w.window[:0], w.window[len(w.window)-mszipWindowSize:]...)
+	}
+
+	return buf.Bytes(), nil
+}
+
+type fileWriter struct {
+	w *Writer
+	f *writerFile
+}
+
+func (fw *fileWriter) Write(p []byte) (int, error) {
+	return fw.w.write(fw.f, p)
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v), byte(v>>8))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,585 @@
+package cab_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestWriter(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf)
+	writeFile(t, w, "small.txt", []byte("small"))
+	writeFile(t, w, `dir\large.bin`, large)
+	writeFile(t, w, "empty.txt", nil)
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string][]byte{
+		"small.txt":                       []byte("small"),
+		filepath.Join("dir", "large.bin"): large,
+		"empty.txt":                       {},
+	}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if !bytes.Equal(b, content) {
+			t.Fatalf("expected %s to have %d byte(s), but got %d", name, len(content), len(b))
+		}
+	}
+}
+
+func TestWriteFiles(t *testing.T) {
+	files := map[string][]byte{
+		"b.txt":         []byte("bbb"),
+		`dir\a.txt`:     []byte("a"),
+		"large.bin":     bytes.Repeat([]byte("0123456789abcdef"), 5000),
+		"empty.txt":     nil,
+		"unicode é.txt": []byte("é"),
+	}
+
+	var first, second bytes.Buffer
+	if err := cab.WriteFiles(&first, files, cab.WithCompression(cab.CompressionMSZIP)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := cab.WriteFiles(&second, files, cab.WithCompression(cab.CompressionMSZIP)); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Fatalf("expected the same files to produce the same cabinet")
+	}
+
+	data := first.Bytes()
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var names []string
+	for _, file := range r.File {
+		names = append(names, file.Name)
+		if b := readFile(t, file); !bytes.Equal(b, files[file.Name]) {
+			t.Fatalf("unexpected content for %s", file.Name)
+		}
+	}
+	if !sort.StringsAreSorted(names) || len(names) != len(files) {
+		t.Fatalf("expected every file in order of name, but got %v", names)
+	}
+
+	if err := cab.WriteFiles(ioutil.Discard, map[string][]byte{"": nil}); err == nil {
+		t.Fatalf("expected an error for an invalid name, but got none")
+	}
+}
+
+func TestWriterCabinetRefs(t *testing.T) {
+	testCases := []struct {
+		name       string
+		prev, next *cab.Ref
+	}{
+		{name: "next", next: &cab.Ref{Name: "data2.cab", Disk: "disk 2"}},
+		{name: "prev", prev: &cab.Ref{Name: "data1.cab"}},
+		{name: "both", prev: &cab.Ref{Name: "data1.cab", Disk: "disk 1"}, next: &cab.Ref{Name: "data3.cab", Disk: "disk 3"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := cab.NewWriter(&buf, cab.WithCompression(cab.CompressionMSZIP))
+			if tc.prev != nil {
+				if err := w.SetPrevCabinet(tc.prev.Name, tc.prev.Disk); err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+			}
+			if tc.next != nil {
+				if err := w.SetNextCabinet(tc.next.Name, tc.next.Disk); err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+			}
+			fw, err := w.Create("a.txt")
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if _, err := fw.Write([]byte("hello")); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			data := buf.Bytes()
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if !reflect.DeepEqual(r.PrevCab, tc.prev) {
+				t.Fatalf("expected %v, but got %v", tc.prev, r.PrevCab)
+			}
+			if !reflect.DeepEqual(r.NextCab, tc.next) {
+				t.Fatalf("expected %v, but got %v", tc.next, r.NextCab)
+			}
+			if b := readFile(t, r.File[0]); string(b) != "hello" {
+				t.Fatalf("expected %q, but got %q", "hello", b)
+			}
+		})
+	}
+
+	t.Run("invalid", func(t *testing.T) {
+		w := cab.NewWriter(ioutil.Discard)
+		if err := w.SetNextCabinet("", "disk 2"); err == nil {
+			t.Fatalf("expected an error for an empty name, but got none")
+		}
+		if err := w.SetPrevCabinet("a\x00b.cab", ""); err == nil {
+			t.Fatalf("expected an error for a name with a NUL, but got none")
+		}
+		if err := w.SetPrevCabinet("a.cab", strings.Repeat("d", 256)); err == nil {
+			t.Fatalf("expected an error for a long disk name, but got none")
+		}
+		for _, index := range []int{-1, 0x10000} {
+			if err := w.SetSetIndex(index); err == nil {
+				t.Fatalf("expected an error for set index %d, but got none", index)
+			}
+		}
+	})
+
+	t.Run("set", func(t *testing.T) {
+		names := []string{"a.cab", "b.cab"}
+		volumes := make(map[string]*cab.Reader)
+		for i, name := range names {
+			var buf bytes.Buffer
+			w := cab.NewWriter(&buf)
+			if err := w.SetSetID(0x1234); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if err := w.SetSetIndex(i); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if i > 0 {
+				if err := w.SetPrevCabinet(names[i-1], ""); err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+			}
+			if i < len(names)-1 {
+				if err := w.SetNextCabinet(names[i+1], ""); err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+			}
+			writeFile(t, w, fmt.Sprintf("%d.txt", i), []byte(name))
+			if err := w.Close(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if r.SetID() != 0x1234 || r.SetIndex() != i {
+				t.Fatalf("expected set 0x1234 index %d, but got 0x%x index %d", i, r.SetID(), r.SetIndex())
+			}
+			volumes[name] = r
+		}
+
+		s, err := cab.NewSetReader(volumes["b.cab"], volumes["a.cab"])
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if len(s.Files()) != 2 {
+			t.Fatalf("expected 2 files in the set, but got %d", len(s.Files()))
+		}
+
+		var visited []string
+		err = volumes["a.cab"].IterateSet(func(ref *cab.Ref) (*cab.Reader, error) {
+			return volumes[ref.Name], nil
+		}, func(file *cab.File) error {
+			visited = append(visited, file.Name)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if expected := []string{"0.txt", "1.txt"}; !reflect.DeepEqual(visited, expected) {
+			t.Fatalf("expected %v, but got %v", expected, visited)
+		}
+	})
+}
+
+func TestWriterAddDir(t *testing.T) {
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf)
+	if err := w.AddDir(`empty\dir`); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	writeFile(t, w, "a.txt", []byte("a"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var found bool
+	for _, folder := range r.Folders {
+		for _, file := range folder.Files {
+			if file.Name == `empty\dir\` {
+				found = true
+				if !file.IsDir() {
+					t.Fatalf("expected %q to be a directory", file.Name)
+				}
+			} else if file.IsDir() {
+				t.Fatalf("expected %q to not be a directory", file.Name)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("did not find the directory marker")
+	}
+
+	dir, err := ioutil.TempDir("", "cab")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := r.ExtractTo(dir); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	fi, err := os.Stat(filepath.Join(dir, "empty", "dir"))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !fi.IsDir() {
+		t.Fatalf("expected a directory")
+	}
+}
+
+func writeFile(t *testing.T, w *cab.Writer, name string, content []byte) {
+	t.Helper()
+
+	fw, err := w.Create(name)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := fw.Write(content); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+}
+
+func TestWriterFlushBlock(t *testing.T) {
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf)
+	writeFile(t, w, "a.txt", []byte("aaa"))
+	if err := w.FlushBlock(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if err := w.FlushBlock(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	writeFile(t, w, "b.txt", []byte("bbbb"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var dump bytes.Buffer
+	if err := cab.Dump(bytes.NewReader(buf.Bytes()), int64(buf.Len()), &dump); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if !strings.Contains(dump.String(), "cCFData          2") {
+		t.Fatalf("expected 2 data blocks, but got:\n%s", dump.String())
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	b, err := r.Folders[0].Files[1].ReadRange(0, 4)
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if string(b) != "bbbb" {
+		t.Fatalf("expected %q, but got %q", "bbbb", b)
+	}
+
+	if err := w.FlushBlock(); err == nil {
+		t.Fatalf("expected an error after Close, but got none")
+	}
+}
+
+func TestWriterCompression(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf, cab.WithCompression(cab.CompressionMSZIP))
+	writeFile(t, w, "small.txt", []byte("small"))
+	writeFile(t, w, "large.bin", large)
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if buf.Len() >= len(large) {
+		t.Fatalf("expected the cabinet to be compressed, but it is %d byte(s)", buf.Len())
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if types := r.CompressionTypesUsed(); len(types) != 1 || types[0] != cab.CompressionMSZIP {
+		t.Fatalf("expected only MSZIP to be used, but got %v", types)
+	}
+
+	for name, content := range map[string][]byte{"small.txt": []byte("small"), "large.bin": large} {
+		file, ok := r.FileByName(name)
+		if !ok {
+			t.Fatalf("expected to find %s", name)
+		}
+		if !bytes.Equal(readFile(t, file), content) {
+			t.Fatalf("unexpected content for %s", name)
+		}
+	}
+
+	w = cab.NewWriter(&buf, cab.WithCompression(cab.CompressionQuantum))
+	if _, err := w.Create("a.txt"); !errors.Is(err, cab.ErrUnsupportedCompression) {
+		t.Fatalf("expected %v to match %v", err, cab.ErrUnsupportedCompression)
+	}
+}
+
+func TestWriterUnknownSize(t *testing.T) {
+	// a source whose size is only known once it has been read to the end
+	content := bytes.Repeat([]byte("streamed content "), 7000)
+	pr, pw := io.Pipe()
+	go func() {
+		for p := content; len(p) > 0; {
+			n := 1000
+			if n > len(p) {
+				n = len(p)
+			}
+			pw.Write(p[:n])
+			p = p[n:]
+		}
+		pw.Close()
+	}()
+
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf)
+	fw, err := w.Create("streamed.txt")
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	if _, err := io.Copy(fw, pr); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	writeFile(t, w, "after.txt", []byte("after"))
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	file, ok := r.FileByName("streamed.txt")
+	if !ok {
+		t.Fatalf("expected to find streamed.txt")
+	}
+	if file.Size() != int64(len(content)) {
+		t.Fatalf("expected a size of %d, but got %d", len(content), file.Size())
+	}
+	if !bytes.Equal(readFile(t, file), content) {
+		t.Fatalf("unexpected content for streamed.txt")
+	}
+
+	file, ok = r.FileByName("after.txt")
+	if !ok {
+		t.Fatalf("expected to find after.txt")
+	}
+	if b := readFile(t, file); string(b) != "after" {
+		t.Fatalf("expected %q, but got %q", "after", b)
+	}
+}
+
+func TestWriterBlockSize(t *testing.T) {
+	content := make([]byte, 100000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	testCases := []struct {
+		name        string
+		compression cab.CompressionType
+		blockSize   int
+	}{
+		{name: "stored", compression: cab.CompressionNone, blockSize: 16},
+		{name: "MSZIP", compression: cab.CompressionMSZIP, blockSize: 1000},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := cab.NewWriter(&buf, cab.WithCompression(tc.compression), cab.WithBlockSize(tc.blockSize))
+			writeFile(t, w, "a.bin", content)
+			if err := w.Close(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			blocks, err := r.Folders[0].Blocks()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if expected := (len(content) + tc.blockSize - 1) / tc.blockSize; len(blocks) != expected {
+				t.Fatalf("expected %d blocks, but got %d", expected, len(blocks))
+			}
+			for i, blk := range blocks {
+				if blk.UncompressedSize > tc.blockSize {
+					t.Fatalf("expected block %d to hold at most %d bytes, but got %d", i, tc.blockSize, blk.UncompressedSize)
+				}
+			}
+
+			file := r.Folders[0].Files[0]
+			if !bytes.Equal(readFile(t, file), content) {
+				t.Fatalf("unexpected content")
+			}
+
+			for _, off := range []int64{0, 12345, int64(len(content)) - 100} {
+				b, err := file.ReadRange(off, 100)
+				if err != nil {
+					t.Fatalf("expected no error, but got %v", err)
+				}
+				if !bytes.Equal(b, content[off:off+100]) {
+					t.Fatalf("unexpected content at %d", off)
+				}
+			}
+		})
+	}
+
+	for _, n := range []int{0, -1, 32769} {
+		w := cab.NewWriter(ioutil.Discard, cab.WithBlockSize(n))
+		if _, err := w.Create("a.txt"); err == nil {
+			t.Fatalf("expected an error for block size %d, but got none", n)
+		}
+	}
+
+	// an earlier problem with the options is not hidden by the block size
+	w := cab.NewWriter(ioutil.Discard, cab.WithCompression(cab.CompressionType(14)), cab.WithBlockSize(0))
+	if _, err := w.Create("a.txt"); !errors.Is(err, cab.ErrUnsupportedCompression) {
+		t.Fatalf("expected ErrUnsupportedCompression, but got %v", err)
+	}
+}
+
+func TestWriterChecksums(t *testing.T) {
+	content := make([]byte, 100003)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	for _, compression := range []cab.CompressionType{cab.CompressionNone, cab.CompressionMSZIP} {
+		t.Run(compression.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			w := cab.NewWriter(&buf, cab.WithCompression(compression))
+			writeFile(t, w, "a.bin", content)
+			writeFile(t, w, "b.txt", []byte("odd"))
+			if err := w.Close(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			data := buf.Bytes()
+
+			r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if err := r.VerifyChecksums(); err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+
+			blocks, err := r.Folders[0].Blocks()
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			for i, blk := range blocks {
+				if blk.Checksum == 0 {
+					t.Fatalf("expected block %d to have a checksum", i)
+				}
+			}
+
+			// a change to the last block's data must be detected
+			last := blocks[len(blocks)-1]
+			data[last.Offset+8] ^= 0x01
+			r, err = cab.NewReader(bytes.NewReader(data), int64(len(data)))
+			if err != nil {
+				t.Fatalf("expected no error, but got %v", err)
+			}
+			if err := r.VerifyChecksums(); !errors.Is(err, cab.ErrChecksum) {
+				t.Fatalf("expected a checksum error, but got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriterMaxFiles(t *testing.T) {
+	const max = 0xffff
+
+	var buf bytes.Buffer
+	w := cab.NewWriter(&buf)
+	for i := 0; i < max; i++ {
+		if _, err := w.Create(fmt.Sprintf("%05d.txt", i)); err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+	}
+	if _, err := w.Create("one-too-many.txt"); err == nil {
+		t.Fatalf("expected an error, but got none")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	r, err := cab.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+	files := r.Folders[0].Files
+	if len(files) != max {
+		t.Fatalf("expected %d files, but got %d", max, len(files))
+	}
+	if expected := fmt.Sprintf("%05d.txt", max-1); files[max-1].Name != expected {
+		t.Fatalf("expected %s, but got %s", expected, files[max-1].Name)
+	}
+}
@@ -0,0 +1,126 @@
+package cab
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// ToZip writes every file of the cabinet to w as a zip archive, converting it in one
+// pass over each folder. Names are written with forward slashes, and each file keeps
+// its DateTime and the mode given by File.Mode. Files are deflated.
+//
+// Zip readers expect an entry for each directory, so one is written for every
+// directory in the cabinet's names as well as for each directory marker. Names are
+// compared ignoring case, as Windows does, and a file whose name is already taken,
+// by another file or a directory, is renamed by adding a number to it, such as
+// "a (2).txt". A name that is not a valid relative path, such as one containing "..",
+// causes an error. ToZip does not close w.
+func (c *Reader) ToZip(w io.Writer) error {
+	if err := c.checkFiles(); err != nil {
+		return err
+	}
+
+	// name every entry before writing, so that directories come ahead of their files
+	used := make(map[string]bool)
+	dirs := make(map[string]*File) // the marker of each directory, if it has one
+	var addDir func(name string)
+	addDir = func(name string) {
+		if name == "." || used[mergeKey(name)] {
+			return
+		}
+		addDir(path.Dir(name))
+		used[mergeKey(name)] = true
+		dirs[name] = nil
+	}
+
+	for _, file := range c.File {
+		name, err := zipName(file)
+		if err != nil {
+			return err
+		}
+		if file.IsDir() {
+			addDir(name)
+			if marker, ok := dirs[name]; ok && marker == nil {
+				dirs[name] = file
+			}
+		} else {
+			addDir(path.Dir(name))
+		}
+	}
+
+	names := make(map[*File]string, len(c.File))
+	for _, file := range c.File {
+		if file.IsDir() {
+			continue
+		}
+		name, _ := zipName(file)
+		if used[mergeKey(name)] {
+			name = renameDuplicate(name, func(key string) bool { return used[key] })
+		}
+		used[mergeKey(name)] = true
+		names[file] = name
+	}
+
+	zw := zip.NewWriter(w)
+
+	dirNames := make([]string, 0, len(dirs))
+	for name := range dirs {
+		dirNames = append(dirNames, name)
+	}
+	sort.Strings(dirNames)
+	for _, name := range dirNames {
+		fh := &zip.FileHeader{Name: name + "/", Method: zip.Store}
+		fh.SetMode(fs.ModeDir | 0755)
+		if marker := dirs[name]; marker != nil {
+			fh.Modified = marker.DateTime
+			fh.SetMode(marker.Mode())
+		}
+		if _, err := zw.CreateHeader(fh); err != nil {
+			return err
+		}
+	}
+
+	for _, folder := range c.Folders {
+		files := make([]*File, 0, len(folder.Files))
+		for _, file := range folder.Files {
+			if !file.IsDir() {
+				files = append(files, file)
+			}
+		}
+
+		err := folder.readFiles(files, nil, func(file *File, r io.Reader) error {
+			fh := &zip.FileHeader{Name: names[file], Method: zip.Deflate, Modified: file.DateTime}
+			fh.SetMode(file.Mode())
+			fw, err := zw.CreateHeader(fh)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(fw, r); err != nil {
+				return fmt.Errorf("cab: converting %q: %w", file.DecodeName(), err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// zipName returns the name of file as a slash separated path, without the trailing
+// separator of a directory marker, or an error if it is not a valid relative path.
+func zipName(file *File) (string, error) {
+	name := normalizeName(file.DecodeName())
+	if file.IsDir() {
+		name = name[:len(name)-1]
+	}
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("cab: invalid file name %q", file.DecodeName())
+	}
+	return name, nil
+}
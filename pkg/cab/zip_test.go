@@ -0,0 +1,114 @@
+package cab_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/craiggwilson/go-cab/pkg/cab"
+)
+
+func TestReaderToZip(t *testing.T) {
+	large := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	data := buildMSZIPCab(
+		[]testFile{
+			{name: `dir\sub\a.txt`, data: []byte("a")},
+			{name: `empty\`},
+			{name: "large.bin", data: large},
+		},
+		[]testFile{
+			{name: `DIR\SUB\A.TXT`, data: []byte("A")},
+			{name: "dir", data: []byte("not a directory")},
+			{name: "other.txt", data: []byte("other")},
+		},
+	)
+	// date and time of the first file, 2021-06-15 13:45:30
+	binary.LittleEndian.PutUint16(data[52+10:], 41<<9|6<<5|15)
+	binary.LittleEndian.PutUint16(data[52+12:], 13<<11|45<<5|15)
+
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := r.ToZip(&buf); err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	expected := map[string]string{
+		"dir/":              "",
+		"dir/sub/":          "",
+		"empty/":            "",
+		"dir/sub/a.txt":     "a",
+		"large.bin":         string(large),
+		"DIR/SUB/A (2).TXT": "A",
+		"dir (2)":           "not a directory",
+		"other.txt":         "other",
+	}
+
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+
+		content, ok := expected[f.Name]
+		if !ok {
+			t.Fatalf("unexpected entry %q", f.Name)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		b, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("expected no error, but got %v", err)
+		}
+		if string(b) != content {
+			t.Fatalf("unexpected content for %s", f.Name)
+		}
+
+		if isDir := f.Name[len(f.Name)-1] == '/'; f.Mode().IsDir() != isDir {
+			t.Fatalf("expected %s to be a directory: %v, but got mode %v", f.Name, isDir, f.Mode())
+		}
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("expected %d entries, but got %v", len(expected), names)
+	}
+
+	// directories come first, so that extracting creates them before their files
+	if dirs := names[:3]; !reflect.DeepEqual(dirs, []string{"dir/", "dir/sub/", "empty/"}) {
+		t.Fatalf("expected the directories first, but got %v", names)
+	}
+
+	for _, f := range zr.File {
+		if f.Name != "dir/sub/a.txt" {
+			continue
+		}
+		expected := time.Date(2021, 6, 15, 13, 45, 30, 0, time.UTC)
+		if !f.Modified.Equal(expected) {
+			t.Fatalf("expected %v, but got %v", expected, f.Modified)
+		}
+	}
+}
+
+func TestReaderToZipInvalidName(t *testing.T) {
+	data := buildCab([]testFile{{name: `..\escape.txt`, data: []byte("x")}})
+	r, err := cab.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("expected no error, but got %v", err)
+	}
+
+	if err := r.ToZip(ioutil.Discard); err == nil {
+		t.Fatalf("expected an error, but got none")
+	}
+}